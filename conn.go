@@ -2,24 +2,127 @@ package dosqlite
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
+var (
+	_ driver.ConnBeginTx       = &Conn{}
+	_ driver.NamedValueChecker = &Conn{}
+	_ driver.Tx                = &Tx{}
+	_ driver.StmtExecContext   = &Stmt{}
+	_ driver.StmtQueryContext  = &Stmt{}
+)
+
+// CheckNamedValue implements driver.NamedValueChecker. It resolves
+// driver.Valuer implementations (sql.NullString, sql.NullInt64, sql.NullTime,
+// etc.) to their underlying value so they round-trip through
+// convertDriverValueToJSON correctly, and otherwise defers to the default
+// converter for anything it doesn't recognize.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if driver.IsValue(nv.Value) {
+		return nil
+	}
+
+	if valuer, ok := nv.Value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return fmt.Errorf("dosqlite: %w", err)
+		}
+		if v != nil && !driver.IsValue(v) {
+			return fmt.Errorf("dosqlite: invalid value returned from Valuer: %T", v)
+		}
+		nv.Value = v
+		return nil
+	}
+
+	return driver.ErrSkip
+}
+
 // Conn implements the database/sql/driver.Conn interface
 type Conn struct {
-	listener listener
-	closed   bool
+	listener       listener
+	pinned         sender
+	closed         bool
+	txID           string
+	requestTimeout time.Duration
+}
+
+// sender returns the sender this Conn should send its next request
+// through: the backend pin locked in by an earlier BeginTx or Prepare, if
+// any, or the listener itself otherwise (which may still fan a stateless
+// request out across a pool).
+func (c *Conn) sender() sender {
+	if c.pinned != nil {
+		return c.pinned
+	}
+	return &c.listener
+}
+
+// pin locks this Conn onto a single backend for the rest of its lifetime,
+// the first time it starts a transaction or prepares a statement. A tx_id
+// or stmt_id is only meaningful on the backend that issued it, which a
+// pool's per-request Strategy can't otherwise guarantee across later
+// requests; pinning makes that guarantee explicit instead. Pinning is
+// permanent rather than released when the transaction/statement ends, to
+// keep the bookkeeping simple, at the cost of the Conn no longer taking
+// part in load balancing once it's pinned.
+func (c *Conn) pin() error {
+	if c.pinned != nil {
+		return nil
+	}
+	s, err := c.listener.pin()
+	if err != nil {
+		return err
+	}
+	c.pinned = s
+	return nil
+}
+
+// withRequestTimeout returns ctx unchanged if the caller already gave it a
+// deadline, otherwise applies the DSN's request_timeout default, if one was
+// configured, mirroring the per-session default timeout gocql exposes.
+func (c *Conn) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.requestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.requestTimeout)
 }
 
-// Prepare returns a prepared statement, bound to this connection
+// Prepare has the worker compile query once via db.prepare() and returns a
+// Stmt bound to the resulting stmt_id, so repeated Exec/Query calls send
+// only the statement id and params rather than the full SQL text.
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+	if err := c.pin(); err != nil {
+		return nil, err
+	}
+
+	req := PrepareRequest{
+		Cmd: "prepare",
+		SQL: query,
+	}
+
+	var resp PrepareResponse
+	if err := c.sendRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if !resp.OK {
+		return nil, fmt.Errorf("dosqlite: %s", resp.Error)
+	}
+
 	return &Stmt{
-		conn:  c,
-		query: query,
+		conn:   c,
+		query:  query,
+		stmtID: resp.StmtID,
 	}, nil
 }
 
@@ -34,7 +137,40 @@ func (c *Conn) Close() error {
 
 // Begin starts and returns a new transaction
 func (c *Conn) Begin() (driver.Tx, error) {
-	return nil, fmt.Errorf("dosqlite: transactions not supported")
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts and returns a new transaction, opening a scoped transaction
+// on the Deno worker. The isolation level, if set, is passed through to the
+// worker for it to apply as best it can.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+	if c.txID != "" {
+		return nil, fmt.Errorf("dosqlite: a transaction is already in progress on this connection")
+	}
+	if err := c.pin(); err != nil {
+		return nil, err
+	}
+
+	req := BeginRequest{
+		Cmd:       "begin",
+		Isolation: sql.IsolationLevel(opts.Isolation).String(),
+		ReadOnly:  opts.ReadOnly,
+	}
+
+	var resp BeginResponse
+	if err := c.sendRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if !resp.OK {
+		return nil, fmt.Errorf("dosqlite: %s", resp.Error)
+	}
+
+	c.txID = resp.TxID
+	return &Tx{conn: c}, nil
 }
 
 // ExecContext executes a query without returning any rows
@@ -43,19 +179,19 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		return nil, driver.ErrBadConn
 	}
 
-	params := make([]interface{}, len(args))
-	for i, arg := range args {
-		params[i] = convertDriverValueToJSON(arg.Value)
-	}
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
 
 	req := ExecRequest{
-		Cmd:    "exec",
-		SQL:    query,
-		Params: params,
+		Cmd:       "exec",
+		SQL:       query,
+		Params:    convertNamedValuesToJSON(args),
+		TxID:      c.txID,
+		TimeoutMs: timeoutMs(ctx),
 	}
 
 	var resp ExecResponse
-	if err := c.sendRequest(req, &resp); err != nil {
+	if err := c.sendRequestContext(ctx, req, &resp); err != nil {
 		return nil, err
 	}
 
@@ -63,16 +199,23 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		return nil, fmt.Errorf("dosqlite: %s", resp.Error)
 	}
 
-	return &Result{}, nil
+	return &Result{
+		lastInsertID: resp.LastInsertRowID,
+		rowsAffected: resp.Changes,
+	}, nil
 }
 
 func (c *Conn) sendRequest(req interface{}, resp interface{}) error {
+	return c.sendRequestContext(context.Background(), req, resp)
+}
+
+func (c *Conn) sendRequestContext(ctx context.Context, req interface{}, resp interface{}) error {
 	// Marshal request to JSON
 	payload, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("dosqlite: failed to marshal request: %w", err)
 	}
-	r, err := c.listener.Send(string(payload))
+	r, err := c.sender().SendContext(ctx, string(payload))
 	if err != nil {
 		return err
 	}
@@ -85,46 +228,148 @@ func (c *Conn) sendRequest(req interface{}, resp interface{}) error {
 	return nil
 }
 
-// QueryContext executes a query that returns rows
+// timeoutMs returns the milliseconds remaining until ctx's deadline, or 0 if
+// ctx has no deadline, for the worker to enforce server-side
+func timeoutMs(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining.Milliseconds()
+}
+
+// QueryContext executes a query that returns rows. The result streams back
+// from the worker in fetchSize-row chunks rather than buffering the whole
+// result set, so the first row is available as soon as the first chunk
+// arrives.
 func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	if c.closed {
 		return nil, driver.ErrBadConn
 	}
 
-	params := make([]interface{}, len(args))
-	for i, arg := range args {
-		params[i] = convertDriverValueToJSON(arg.Value)
-	}
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
 
 	req := QueryRequest{
-		Cmd:    "query",
-		SQL:    query,
-		Params: params,
+		Cmd:       "query",
+		SQL:       query,
+		Params:    convertNamedValuesToJSON(args),
+		TxID:      c.txID,
+		TimeoutMs: timeoutMs(ctx),
+		FetchSize: defaultFetchSize,
 	}
 
-	var resp QueryResponse
-	if err := c.sendRequest(req, &resp); err != nil {
+	return c.streamQuery(ctx, req)
+}
+
+// streamQuery marshals req, opens a streamed response, and primes the
+// returned Rows with its column metadata before handing it to the caller
+func (c *Conn) streamQuery(ctx context.Context, req interface{}) (driver.Rows, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("dosqlite: failed to marshal request: %w", err)
+	}
+
+	frames, err := c.sender().SendStreamContext(ctx, string(payload), isQueryStreamTerminal)
+	if err != nil {
 		return nil, err
 	}
 
+	rows := &Rows{frames: frames}
+	if err := rows.primeColumns(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// isQueryStreamTerminal reports whether frame is the last frame of a query
+// stream (a Done or Error frame)
+func isQueryStreamTerminal(frame string) bool {
+	var f QueryStreamFrame
+	if err := json.Unmarshal([]byte(frame), &f); err != nil {
+		return true
+	}
+	return f.Done || f.Error != ""
+}
+
+// Tx implements the database/sql/driver.Tx interface. It represents a
+// transaction scoped to a single connection and open on the Deno worker.
+type Tx struct {
+	conn *Conn
+}
+
+// Commit commits the transaction on the worker. txID is only cleared once
+// the worker has actually confirmed the commit; a failed request or an
+// OK:false response leaves the transaction open so a caller can retry
+// (or a deferred Rollback can still reach the worker with a valid TxID).
+func (t *Tx) Commit() error {
+	req := CommitRequest{
+		Cmd:  "commit",
+		TxID: t.conn.txID,
+	}
+
+	var resp CommitResponse
+	if err := t.conn.sendRequest(req, &resp); err != nil {
+		return err
+	}
+
 	if !resp.OK {
-		return nil, fmt.Errorf("dosqlite: %s", resp.Error)
+		return fmt.Errorf("dosqlite: %s", resp.Error)
 	}
 
-	return &Rows{
-		rows:    resp.Rows,
-		current: -1,
-	}, nil
+	t.conn.txID = ""
+	return nil
+}
+
+// Rollback rolls back the transaction on the worker. As with Commit, txID
+// is only cleared once the worker has confirmed the rollback.
+func (t *Tx) Rollback() error {
+	req := RollbackRequest{
+		Cmd:  "rollback",
+		TxID: t.conn.txID,
+	}
+
+	var resp RollbackResponse
+	if err := t.conn.sendRequest(req, &resp); err != nil {
+		return err
+	}
+
+	if !resp.OK {
+		return fmt.Errorf("dosqlite: %s", resp.Error)
+	}
+
+	t.conn.txID = ""
+	return nil
 }
 
-// Stmt implements the database/sql/driver.Stmt interface
+// Stmt implements the database/sql/driver.Stmt interface, backed by a
+// statement compiled and cached on the worker under stmtID
 type Stmt struct {
-	conn  *Conn
-	query string
+	conn   *Conn
+	query  string
+	stmtID string
 }
 
-// Close closes the statement
+// Close releases the cached statement on the worker
 func (s *Stmt) Close() error {
+	req := CloseStmtRequest{
+		Cmd:    "close_stmt",
+		StmtID: s.stmtID,
+	}
+
+	var resp CloseStmtResponse
+	if err := s.conn.sendRequest(req, &resp); err != nil {
+		return err
+	}
+
+	if !resp.OK {
+		return fmt.Errorf("dosqlite: %s", resp.Error)
+	}
+
 	return nil
 }
 
@@ -133,72 +378,205 @@ func (s *Stmt) NumInput() int {
 	return strings.Count(s.query, "?")
 }
 
-// Exec executes a query without returning any rows
+// Exec executes the prepared statement without returning any rows
 func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
-	namedArgs := make([]driver.NamedValue, len(args))
-	for i, arg := range args {
-		namedArgs[i] = driver.NamedValue{Value: arg}
+	return s.ExecContext(context.Background(), namedValuesFromValues(args))
+}
+
+// ExecContext executes the prepared statement without returning any rows,
+// honoring ctx's deadline and cancellation (and the DSN's request_timeout
+// default) the same way Conn.ExecContext does.
+func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.conn.closed {
+		return nil, driver.ErrBadConn
+	}
+
+	ctx, cancel := s.conn.withRequestTimeout(ctx)
+	defer cancel()
+
+	req := ExecStmtRequest{
+		Cmd:       "exec_stmt",
+		StmtID:    s.stmtID,
+		Params:    convertNamedValuesToJSON(args),
+		TxID:      s.conn.txID,
+		TimeoutMs: timeoutMs(ctx),
 	}
-	return s.conn.ExecContext(context.Background(), s.query, namedArgs)
+
+	var resp ExecResponse
+	if err := s.conn.sendRequestContext(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+
+	if !resp.OK {
+		return nil, fmt.Errorf("dosqlite: %s", resp.Error)
+	}
+
+	return &Result{
+		lastInsertID: resp.LastInsertRowID,
+		rowsAffected: resp.Changes,
+	}, nil
 }
 
-// Query executes a query that may return rows
+// Query executes the prepared statement and returns the resulting rows
 func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
-	namedArgs := make([]driver.NamedValue, len(args))
-	for i, arg := range args {
-		namedArgs[i] = driver.NamedValue{Value: arg}
+	return s.QueryContext(context.Background(), namedValuesFromValues(args))
+}
+
+// QueryContext executes the prepared statement and returns the resulting
+// rows, honoring ctx's deadline and cancellation (and the DSN's
+// request_timeout default) the same way Conn.QueryContext does.
+func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.conn.closed {
+		return nil, driver.ErrBadConn
+	}
+
+	ctx, cancel := s.conn.withRequestTimeout(ctx)
+	defer cancel()
+
+	req := QueryStmtRequest{
+		Cmd:       "query_stmt",
+		StmtID:    s.stmtID,
+		Params:    convertNamedValuesToJSON(args),
+		TxID:      s.conn.txID,
+		FetchSize: defaultFetchSize,
+		TimeoutMs: timeoutMs(ctx),
 	}
-	return s.conn.QueryContext(context.Background(), s.query, namedArgs)
+
+	return s.conn.streamQuery(ctx, req)
+}
+
+// namedValuesFromValues adapts the legacy driver.Stmt Exec/Query signature
+// (a plain []driver.Value) to []driver.NamedValue, assigning each its
+// 1-based positional ordinal, so both call paths can share one
+// ExecContext/QueryContext implementation.
+func namedValuesFromValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
 }
 
 // Result implements the database/sql/driver.Result interface
-type Result struct{}
+type Result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
 
-// LastInsertId returns the database's auto-generated ID after an INSERT
+// LastInsertId returns the database's auto-generated ID after an INSERT,
+// mirroring SQLite's sqlite3_last_insert_rowid()
 func (r *Result) LastInsertId() (int64, error) {
-	return 0, fmt.Errorf("dosqlite: LastInsertId not supported")
+	return r.lastInsertID, nil
 }
 
-// RowsAffected returns the number of rows affected by the query
+// RowsAffected returns the number of rows affected by the query, mirroring
+// SQLite's sqlite3_changes()
 func (r *Result) RowsAffected() (int64, error) {
-	return 0, fmt.Errorf("dosqlite: RowsAffected not supported")
+	return r.rowsAffected, nil
 }
 
-// Rows implements the database/sql/driver.Rows interface
+// Rows implements the database/sql/driver.Rows interface. Rows are pulled
+// lazily, fetchSize at a time, off a bounded channel fed by the connection's
+// background read loop, rather than being buffered up front.
 type Rows struct {
-	rows    []map[string]interface{}
-	current int
-	columns []string
+	columns     []string
+	columnTypes []string
+	frames      <-chan streamFrame
+	buf         [][]interface{}
+	bufIdx      int
+}
+
+var _ driver.RowsColumnTypeDatabaseTypeName = &Rows{}
+
+// primeColumns reads the stream's first frame, which carries column
+// metadata, so Columns() has an answer before Next is ever called. Over the
+// wire this first frame never carries rows, but a handler registered via
+// RegisterLocal answers in a single frame, so any Chunk it also carries is
+// kept rather than discarded.
+func (r *Rows) primeColumns() error {
+	frame, ok := <-r.frames
+	if !ok {
+		return fmt.Errorf("dosqlite: query stream closed before any frame arrived")
+	}
+	if frame.err != nil {
+		return frame.err
+	}
+
+	var f QueryStreamFrame
+	if err := json.Unmarshal([]byte(frame.data), &f); err != nil {
+		return fmt.Errorf("dosqlite: failed to unmarshal query stream frame: %w", err)
+	}
+	if f.Error != "" {
+		return fmt.Errorf("dosqlite: %s", f.Error)
+	}
+
+	r.columns = f.Columns
+	r.columnTypes = f.ColumnTypes
+	r.buf = f.Chunk
+	return nil
 }
 
 // Columns returns the names of the columns
 func (r *Rows) Columns() []string {
-	if len(r.columns) == 0 && len(r.rows) > 0 {
-		for col := range r.rows[0] {
-			r.columns = append(r.columns, col)
-		}
-	}
 	return r.columns
 }
 
-// Close closes the rows iterator
+// ColumnTypeDatabaseTypeName returns the SQLite declared type of the column
+// at the given index, e.g. "INTEGER", "TEXT", "REAL", "BLOB"
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	if index >= len(r.columnTypes) {
+		return ""
+	}
+	return r.columnTypes[index]
+}
+
+// Close drains any remaining frames in the background so the connection's
+// read loop, which may be blocked sending the next one, isn't left stuck
+// feeding a channel nobody reads anymore
 func (r *Rows) Close() error {
+	if r.frames == nil {
+		return nil
+	}
+	frames := r.frames
+	r.frames = nil
+	go func() {
+		for range frames {
+		}
+	}()
 	return nil
 }
 
 // Next is called to populate the next row of data into the provided slice
 func (r *Rows) Next(dest []driver.Value) error {
-	r.current++
-	if r.current >= len(r.rows) {
-		return io.EOF
-	}
+	for r.bufIdx >= len(r.buf) {
+		frame, ok := <-r.frames
+		if !ok {
+			return io.EOF
+		}
+		if frame.err != nil {
+			return frame.err
+		}
 
-	row := r.rows[r.current]
-	columns := r.Columns()
+		var f QueryStreamFrame
+		if err := json.Unmarshal([]byte(frame.data), &f); err != nil {
+			return fmt.Errorf("dosqlite: failed to unmarshal query stream frame: %w", err)
+		}
+		if f.Error != "" {
+			return fmt.Errorf("dosqlite: %s", f.Error)
+		}
+		if f.Done {
+			return io.EOF
+		}
+
+		r.buf = f.Chunk
+		r.bufIdx = 0
+	}
 
-	for i, col := range columns {
-		if i < len(dest) {
-			dest[i] = convertJSONValueToDriver(row[col])
+	row := r.buf[r.bufIdx]
+	r.bufIdx++
+	for i := range dest {
+		if i < len(row) {
+			dest[i] = convertJSONValueToDriver(row[i])
 		}
 	}
 
@@ -5,6 +5,7 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"net/url"
+	"time"
 )
 
 func init() {
@@ -16,22 +17,65 @@ type Driver struct{}
 
 var _ driver.Driver = &Driver{}
 
-// Open opens a new connection to the DoSQLite database
+// Open opens a new connection to the DoSQLite database. The DSN scheme
+// selects the transport: "dosqlite" for plain TCP, "dosqlites" for TLS
+// (optionally mutual TLS), configured via the "cert", "key", and "ca" query
+// parameters documented on tlsConfigFromQuery. The "request_timeout" query
+// parameter, parsed with time.ParseDuration (e.g. "5s"), sets the default
+// deadline applied to a call that's made with context.Background(); a call
+// that already carries its own deadline is left alone. The "max_backends"
+// and "strategy" query parameters, documented on listenerOptionsFromQuery,
+// configure a multi-backend pool the first time a DSN host is opened. If a
+// handler was registered for the DSN's host via RegisterLocal, Open calls
+// it directly instead of dialing out over TCP; otherwise, if a pool is
+// already listening on the DSN's host (from an earlier Open, or a direct
+// AddListener* call), Open attaches to it rather than trying to net.Listen
+// on the same address a second time, which would fail.
 func (d *Driver) Open(name string) (driver.Conn, error) {
 	u, err := url.Parse(name)
 	if err != nil {
 		return nil, fmt.Errorf("dosqlite: invalid connection string: %w", err)
 	}
 
-	if u.Scheme != "dosqlite" {
-		return nil, fmt.Errorf("dosqlite: invalid scheme, expected 'dosqlite'")
+	var requestTimeout time.Duration
+	if s := u.Query().Get("request_timeout"); s != "" {
+		requestTimeout, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("dosqlite: invalid request_timeout: %w", err)
+		}
 	}
-	listener := AddListener(u.Host)
-	if err := listener.Ready(); err != nil {
+
+	var lis listener
+	if handler, ok := getLocalHandler(u.Host); ok {
+		lis = listener{addr: u.Host, local: handler}
+	} else if existing, ok := lookupListener(u.Host); ok {
+		lis = existing
+	} else {
+		opts, err := listenerOptionsFromQuery(u.Query())
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case "dosqlite":
+			lis = AddListenerWithOptions(u.Host, opts)
+		case "dosqlites":
+			cfg, err := tlsConfigFromQuery(u.Query())
+			if err != nil {
+				return nil, err
+			}
+			lis = AddListenerTLSWithOptions(u.Host, cfg, opts)
+		default:
+			return nil, fmt.Errorf("dosqlite: invalid scheme, expected 'dosqlite' or 'dosqlites'")
+		}
+	}
+
+	if err := lis.Ready(); err != nil {
 		return nil, fmt.Errorf("dosqlite: failed to connect to worker: %w", err)
 	}
 
 	return &Conn{
-		listener: listener,
+		listener:       lis,
+		requestTimeout: requestTimeout,
 	}, nil
 }
@@ -1,9 +1,11 @@
 package dosqlite
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"testing"
+	"time"
 )
 
 func TestDriverRegistration(t *testing.T) {
@@ -35,38 +37,69 @@ func TestConnectionStringParsing(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for invalid URL")
 	}
+
+	// Test invalid request_timeout
+	_, err = d.Open("dosqlite://127.0.0.1:0?request_timeout=not-a-duration")
+	if err == nil {
+		t.Error("expected error for invalid request_timeout")
+	}
+}
+
+func TestConn_WithRequestTimeout(t *testing.T) {
+	c := &Conn{requestTimeout: 5 * time.Second}
+
+	ctx, cancel := c.withRequestTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("expected a deadline to be applied")
+	}
+	if remaining := time.Until(deadline); remaining <= 0 || remaining > 5*time.Second {
+		t.Errorf("expected a deadline within 5s, got %v", remaining)
+	}
+
+	already, cancelAlready := context.WithTimeout(context.Background(), time.Second)
+	defer cancelAlready()
+	ctx2, cancel2 := c.withRequestTimeout(already)
+	defer cancel2()
+	if ctx2 != already {
+		t.Error("expected an existing deadline to be left alone")
+	}
+
+	noDefault := &Conn{}
+	ctx3, cancel3 := noDefault.withRequestTimeout(context.Background())
+	defer cancel3()
+	if ctx3 != context.Background() {
+		t.Error("expected context.Background() to pass through unchanged with no request_timeout configured")
+	}
 }
 
 func TestValueConversion(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    driver.Value
-		expected interface{}
+		expected JSONValue
 	}{
-		{"nil", nil, nil},
-		{"int64", int64(42), int64(42)},
-		{"float64", float64(3.14), float64(3.14)},
-		{"string", "hello", "hello"},
-		{"bool", true, true},
-		{"empty bytes", []byte{}, BlobValue{Type: "blob", Data: ""}},
-		{"bytes", []byte{1, 2, 3}, BlobValue{Type: "blob", Data: "AQID"}},
+		{"nil", nil, JSONValue{Type: "null"}},
+		{"int64", int64(42), JSONValue{Type: "int", V: "42"}},
+		{"big int64", int64(1 << 60), JSONValue{Type: "int", V: "1152921504606846976"}},
+		{"float64", float64(3.14), JSONValue{Type: "real", V: 3.14}},
+		{"string", "hello", JSONValue{Type: "text", V: "hello"}},
+		{"bool true", true, JSONValue{Type: "int", V: "1"}},
+		{"bool false", false, JSONValue{Type: "int", V: "0"}},
+		{"empty bytes", []byte{}, JSONValue{Type: "blob", V: ""}},
+		{"bytes", []byte{1, 2, 3}, JSONValue{Type: "blob", V: "AQID"}},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), JSONValue{Type: "time", V: "2024-01-02T03:04:05Z"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := convertDriverValueToJSON(tt.input)
-			if tt.name == "bytes" || tt.name == "empty bytes" {
-				blob, ok := result.(BlobValue)
-				if !ok {
-					t.Errorf("expected BlobValue, got %T", result)
-					return
-				}
-				expected := tt.expected.(BlobValue)
-				if blob.Type != expected.Type || blob.Data != expected.Data {
-					t.Errorf("got %+v, want %+v", blob, expected)
-				}
-			} else if result != tt.expected {
-				t.Errorf("got %v, want %v", result, tt.expected)
+			result, ok := convertDriverValueToJSON(tt.input).(JSONValue)
+			if !ok {
+				t.Fatalf("expected JSONValue, got %T", result)
+			}
+			if result != tt.expected {
+				t.Errorf("got %+v, want %+v", result, tt.expected)
 			}
 		})
 	}
@@ -75,39 +108,50 @@ func TestValueConversion(t *testing.T) {
 func TestJSONValueConversion(t *testing.T) {
 	tests := []struct {
 		name     string
-		input    interface{}
+		input    map[string]interface{}
 		expected driver.Value
 	}{
-		{"nil", nil, nil},
-		{"float64 int", float64(42), int64(42)},
-		{"float64 decimal", float64(3.14), float64(3.14)},
-		{"string", "hello", "hello"},
-		{"bool", true, true},
-		{"blob", map[string]interface{}{"type": "blob", "data": "AQID"}, []byte{1, 2, 3}},
+		{"null", map[string]interface{}{"type": "null"}, nil},
+		{"int", map[string]interface{}{"type": "int", "v": "42"}, int64(42)},
+		{"big int", map[string]interface{}{"type": "int", "v": "9223372036854775807"}, int64(9223372036854775807)},
+		{"real", map[string]interface{}{"type": "real", "v": float64(3.14)}, float64(3.14)},
+		{"text", map[string]interface{}{"type": "text", "v": "hello"}, "hello"},
+		{"blob", map[string]interface{}{"type": "blob", "v": "AQID"}, []byte{1, 2, 3}},
+		{"time", map[string]interface{}{"type": "time", "v": "2024-01-02T03:04:05Z"}, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := convertJSONValueToDriver(tt.input)
-			if tt.name == "blob" {
-				bytes, ok := result.([]byte)
-				if !ok {
-					t.Errorf("expected []byte, got %T", result)
-					return
+			if bytes, ok := tt.expected.([]byte); ok {
+				got, ok := result.([]byte)
+				if !ok || !bytesEqual(got, bytes) {
+					t.Errorf("got %v (%T), want %v", result, result, tt.expected)
 				}
-				expected := tt.expected.([]byte)
-				if len(bytes) != len(expected) {
-					t.Errorf("got length %d, want %d", len(bytes), len(expected))
-					return
-				}
-				for i, b := range bytes {
-					if b != expected[i] {
-						t.Errorf("byte %d: got %d, want %d", i, b, expected[i])
-					}
+				return
+			}
+			if ts, ok := tt.expected.(time.Time); ok {
+				got, ok := result.(time.Time)
+				if !ok || !got.Equal(ts) {
+					t.Errorf("got %v (%T), want %v", result, result, tt.expected)
 				}
-			} else if result != tt.expected {
+				return
+			}
+			if result != tt.expected {
 				t.Errorf("got %v (%T), want %v (%T)", result, result, tt.expected, tt.expected)
 			}
 		})
 	}
 }
+
+func bytesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
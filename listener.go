@@ -2,22 +2,40 @@ package dosqlite
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
-var listenerConns = make(map[string]*listenerConn)
-var listenerConnsMu sync.RWMutex
+var listenerPools = make(map[string]*pool)
+var listenerPoolsMu sync.RWMutex
+
+// protocolVersion is exchanged in a one-byte handshake as soon as a
+// connection is accepted. It was bumped when request-ID-tagged framing
+// replaced the original one-request-at-a-time wire format; a peer that
+// doesn't know to echo this byte back fails the handshake immediately
+// instead of the two sides silently disagreeing about frame boundaries.
+const protocolVersion = 2
 
 type listenerConn struct {
+	id        string
 	conn      net.Conn
-	writeChan chan string
-	readChan  chan sendResp
+	writeChan chan writeReq
 	ctx       context.Context
 	cancel    context.CancelCauseFunc
+
+	nextID    atomic.Uint64
+	pendingMu sync.Mutex
+	pending   map[uint64]*pendingReq
+
+	pendingCount atomic.Int64
+	sentCount    atomic.Int64
+	errorCount   atomic.Int64
 }
 
 type sendResp struct {
@@ -25,43 +43,173 @@ type sendResp struct {
 	err  error
 }
 
+// pendingReq records where the response to an in-flight request ID should
+// be delivered, so the reader goroutine can match frames to callers even
+// while several requests are outstanding on the same connection. For a
+// plain request/response exchange, stream is nil and the single response
+// lands on respCh. For a streamed exchange (a query that may return many
+// frames under the same request ID), every frame is forwarded to stream
+// instead, until isTerminal reports the stream is over.
+type pendingReq struct {
+	respCh     chan sendResp
+	stream     chan<- streamFrame
+	isTerminal func(frame string) bool
+
+	// written is set once writeLoop has fully written this request's
+	// frame to the wire. A connection failure discovered afterward is
+	// ambiguous (the backend may have already received and acted on the
+	// request), so failAll consults it to tell pool.sendContext whether a
+	// cross-backend retry would be safe.
+	written atomic.Bool
+}
+
+// errAmbiguousWrite wraps a send failure that happened after the request's
+// frame was already fully written to the wire, so the backend may have
+// already received and processed it. pool.sendContext must not retry a
+// non-idempotent command (an "exec", "commit", ...) against a different
+// backend on this error, since the first backend's write may have already
+// applied: that would risk silently duplicating it.
+type errAmbiguousWrite struct {
+	err error
+}
+
+func (e *errAmbiguousWrite) Error() string { return e.err.Error() }
+func (e *errAmbiguousWrite) Unwrap() error { return e.err }
+
+// writeReq is one entry on writeChan, tagged with the request ID its
+// response will be matched against.
+type writeReq struct {
+	id  uint64
+	msg string
+}
+
+// streamFrame is one frame delivered to a streaming Send's channel
+type streamFrame struct {
+	data string
+	err  error
+}
+
 type listener struct {
 	addr string
 	ln   net.Listener
+
+	// owned reports whether this listener created the pool at addr (via
+	// net.Listen) and so is responsible for tearing it down on Close. A
+	// listener obtained by attaching to an existing pool (see
+	// lookupListener) leaves owned false, so closing it doesn't pull the
+	// pool out from under every other Conn still using the same address.
+	owned bool
+
+	// local, when set, is an in-process handler registered via
+	// RegisterLocal; every method below short-circuits straight to it
+	// instead of consulting listenerPools, skipping TCP entirely.
+	local func(ctx context.Context, msg string) (string, error)
+}
+
+// lookupListener returns a listener attached to the pool already bound to
+// addr, if one exists, instead of attempting a second net.Listen on the
+// same address (which would fail, since only one listener can bind a
+// given TCP address at a time). This is how Driver.Open lets more than
+// one *sql.DB share a single pool of backend connections behind the same
+// DSN host.
+func lookupListener(addr string) (listener, bool) {
+	listenerPoolsMu.RLock()
+	defer listenerPoolsMu.RUnlock()
+	if _, ok := listenerPools[addr]; !ok {
+		return listener{}, false
+	}
+	return listener{addr: addr}, true
+}
+
+// sender is the common interface implemented by both listener and
+// listenerConn. A Conn normally talks to its listener, which may itself
+// fan out a request across a pool of several listenerConns; once a Conn
+// pins to one via listener.pin, it talks to that specific listenerConn
+// instead, so every request for the life of a transaction or prepared
+// statement lands on the backend that issued its tx_id or stmt_id.
+type sender interface {
+	SendContext(ctx context.Context, msg string) (resp string, err error)
+	SendStreamContext(ctx context.Context, msg string, isTerminal func(frame string) bool) (<-chan streamFrame, error)
+}
+
+var (
+	_ sender = &listener{}
+	_ sender = &listenerConn{}
+)
+
+// pin returns the single sender a Conn should stick with for the rest of
+// its stateful operations (a transaction or a prepared statement), so a
+// tx_id or stmt_id issued by one backend is never sent to another. For a
+// local handler, or a pool running StrategyExclusive, that's just l
+// itself; for a multi-backend pool it's whichever specific listenerConn
+// picks up the first request, since StrategyRoundRobin and
+// StrategyLeastOutstanding otherwise reconsider on every send.
+func (l *listener) pin() (sender, error) {
+	if l.local != nil {
+		return l, nil
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+	if p == nil {
+		return nil, fmt.Errorf("listener not found")
+	}
+	if p.opts.Strategy == StrategyExclusive {
+		return l, nil
+	}
+
+	lc, _, err := p.pickExcluding(nil)
+	if err != nil {
+		return nil, err
+	}
+	return lc, nil
 }
 
-// Ready waits for the listener to be ready or returns if there is already an
-// active listener.
+// Ready waits for the listener to have at least one connected backend, or
+// returns immediately if it already does.
 func (l *listener) Ready() error {
-	listenerConnsMu.RLock()
-	lis := listenerConns[l.addr]
-	if lis == nil {
-		listenerConnsMu.RUnlock()
+	if l.local != nil {
+		return nil
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+	if p == nil {
 		return fmt.Errorf("listener not found")
 	}
-	listenerConnsMu.RUnlock()
-	if lis.conn != nil {
+	if p.ready() {
 		return nil
 	}
-	<-lis.ctx.Done()
-	if err := context.Cause(lis.ctx); err != context.Canceled {
+
+	ctx := p.currentCtx()
+	<-ctx.Done()
+	if err := context.Cause(ctx); err != context.Canceled {
 		return err
 	}
 	return nil
 }
 
-// Next waits for the listener to be ready or for the next connection to be
-// live.
+// Next waits for the listener's set of backends to change (a connection
+// arriving, being replaced, or being lost).
 func (l *listener) Next() error {
-	listenerConnsMu.RLock()
-	lis := listenerConns[l.addr]
-	if lis == nil {
-		listenerConnsMu.RUnlock()
+	if l.local != nil {
+		// A registered local handler never changes, so block as if no
+		// change will ever come rather than busy-looping callers.
+		select {}
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+	if p == nil {
 		return fmt.Errorf("listener not found")
 	}
-	listenerConnsMu.RUnlock()
-	<-lis.ctx.Done()
-	if err := context.Cause(lis.ctx); err != context.Canceled {
+
+	ctx := p.currentCtx()
+	<-ctx.Done()
+	if err := context.Cause(ctx); err != context.Canceled {
 		return err
 	}
 	return nil
@@ -70,149 +218,477 @@ func (l *listener) Next() error {
 // Send sends a message to the listener, consider removing before publishing.
 // This should really only be used by the db driver.
 func (l *listener) Send(msg string) (resp string, err error) {
-	listenerConnsMu.RLock()
-	lis := listenerConns[l.addr]
-	listenerConnsMu.RUnlock()
+	return l.SendContext(context.Background(), msg)
+}
 
-	if lis == nil {
+// SendContext sends a message to the listener, honoring ctx's deadline and
+// cancellation in addition to the listener's own lifecycle. When more than
+// one backend is registered, the pool's Strategy picks which one serves the
+// request, retrying on another if the chosen backend's connection fails.
+func (l *listener) SendContext(ctx context.Context, msg string) (resp string, err error) {
+	if l.local != nil {
+		return l.local(ctx, msg)
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+
+	if p == nil {
 		return "", fmt.Errorf("listener not found")
 	}
 
-	return lis.Send(msg)
+	return p.sendContext(ctx, msg)
+}
+
+// SendStreamContext sends a message and returns a channel fed with every
+// response frame the worker sends back, for callers (queries) that expect a
+// streamed result rather than a single response.
+func (l *listener) SendStreamContext(ctx context.Context, msg string, isTerminal func(frame string) bool) (<-chan streamFrame, error) {
+	if l.local != nil {
+		resp, err := l.local(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		frames := make(chan streamFrame, 1)
+		frames <- streamFrame{data: resp}
+		close(frames)
+		return frames, nil
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+
+	if p == nil {
+		return nil, fmt.Errorf("listener not found")
+	}
+
+	return p.sendStreamContext(ctx, msg, isTerminal)
+}
+
+// Backends reports the identity and traffic of every backend currently
+// registered to the listener, in the order they connected.
+func (l *listener) Backends() []BackendInfo {
+	if l.local != nil {
+		return []BackendInfo{{ID: "local:" + l.addr}}
+	}
+
+	listenerPoolsMu.RLock()
+	p := listenerPools[l.addr]
+	listenerPoolsMu.RUnlock()
+
+	if p == nil {
+		return nil
+	}
+	return p.snapshot()
 }
 
 func (l *listener) Close() error {
-	listenerConnsMu.Lock()
-	defer listenerConnsMu.Unlock()
+	if l.local != nil {
+		// The registration is process-wide and outlives any one Conn, so
+		// closing a Conn built over it doesn't unregister the handler;
+		// call UnregisterLocal explicitly to do that.
+		return nil
+	}
+	if !l.owned {
+		// Attached to a pool another listener created (see
+		// lookupListener); tearing it down here would pull it out from
+		// under every other Conn sharing the same address.
+		return nil
+	}
+
+	listenerPoolsMu.Lock()
+	defer listenerPoolsMu.Unlock()
 	_ = l.ln.Close()
 
-	lis := listenerConns[l.addr]
-	if lis == nil {
+	p := listenerPools[l.addr]
+	if p == nil {
 		return fmt.Errorf("listener not found")
 	}
 
-	lis.cancel(nil)
-	if lis.conn != nil {
-		_ = lis.conn.Close()
-	}
-	delete(listenerConns, l.addr)
+	p.closeAll()
+	delete(listenerPools, l.addr)
 	return nil
 }
 
+// AddListener starts a listener that keeps exactly one backend connection
+// at a time: a new connection immediately replaces whatever came before it.
 func AddListener(addr string) listener {
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		panic(err)
 	}
+	return addListener(ln, ListenerOptions{Strategy: StrategyExclusive})
+}
 
-	actualAddr := ln.Addr().String()
+// AddListenerTLS is AddListener's TLS counterpart: the listener only
+// accepts connections that complete a TLS handshake against cfg first, so a
+// dosqlite endpoint can be exposed across a network that isn't otherwise
+// trusted. Setting cfg.ClientAuth to tls.RequireAndVerifyClientCert turns
+// this into mTLS, rejecting workers that don't present a cert signed by
+// cfg.ClientCAs.
+func AddListenerTLS(addr string, cfg *tls.Config) listener {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	return addListener(tls.NewListener(ln, cfg), ListenerOptions{Strategy: StrategyExclusive})
+}
+
+// AddListenerWithOptions is AddListener's multi-backend counterpart: rather
+// than one backend replacing the last, it pools up to opts.MaxBackends
+// connections (0 means unlimited) and spreads Send across them according to
+// opts.Strategy, so a single dosqlite DSN can fan out across several worker
+// instances behind the same address.
+func AddListenerWithOptions(addr string, opts ListenerOptions) listener {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	return addListener(ln, opts)
+}
 
-	listenerConnsMu.Lock()
-	ctx, cancel := context.WithCancelCause(context.Background())
-	listenerConns[actualAddr] = &listenerConn{
-		ctx:       ctx,
-		cancel:    cancel,
-		writeChan: make(chan string, 100),
-		readChan:  make(chan sendResp, 100),
+// AddListenerTLSWithOptions combines AddListenerTLS's certificate-checked
+// transport with AddListenerWithOptions's multi-backend pooling.
+func AddListenerTLSWithOptions(addr string, cfg *tls.Config, opts ListenerOptions) listener {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
 	}
-	listenerConnsMu.Unlock()
+	return addListener(tls.NewListener(ln, cfg), opts)
+}
+
+func addListener(ln net.Listener, opts ListenerOptions) listener {
+	actualAddr := ln.Addr().String()
+
+	p := newPool(opts)
+	listenerPoolsMu.Lock()
+	listenerPools[actualAddr] = p
+	listenerPoolsMu.Unlock()
 
 	go func() {
 		defer ln.Close()
+		var autoID uint64
 		for {
 			conn, err := ln.Accept()
 			if err != nil {
-				listenerConnsMu.Lock()
-				lis := listenerConns[actualAddr]
-				if lis != nil {
-					lis.cancel(err)
+				listenerPoolsMu.RLock()
+				cur := listenerPools[actualAddr]
+				listenerPoolsMu.RUnlock()
+				if cur == p {
+					p.mu.Lock()
+					cancel := p.bumpGeneration()
+					p.mu.Unlock()
+					cancel(err)
 				}
-				listenerConnsMu.Unlock()
 				return
 			}
-			listenerConnsMu.Lock()
-			old := listenerConns[actualAddr]
-			if old != nil {
-				old.cancel(nil) // cancel the previous listener
+
+			identity, err := handshake(conn)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			if identity == "" {
+				autoID++
+				identity = fmt.Sprintf("backend-%d", autoID)
 			}
+
 			ctx, cancel := context.WithCancelCause(context.Background())
-			l := &listenerConn{
+			lc := &listenerConn{
+				id:        identity,
 				conn:      conn,
-				writeChan: make(chan string, 100),
-				readChan:  make(chan sendResp, 100),
+				writeChan: make(chan writeReq, 100),
+				pending:   make(map[uint64]*pendingReq),
 				ctx:       ctx,
 				cancel:    cancel,
 			}
-			listenerConns[actualAddr] = l
-			go l.runLoop(ctx)
-			listenerConnsMu.Unlock()
+			go lc.writeLoop(ctx)
+			go lc.readLoop(ctx)
+
+			p.add(identity, lc)
+			go p.watch(identity, lc)
 		}
 	}()
-	return listener{addr: actualAddr, ln: ln}
+	return listener{addr: actualAddr, ln: ln, owned: true}
+}
+
+// handshake exchanges a one-byte protocol version, then an optional
+// length-prefixed backend identity, with a freshly accepted connection. An
+// empty identity means the worker didn't supply one and the caller should
+// assign one.
+func handshake(conn net.Conn) (identity string, err error) {
+	if _, err := conn.Write([]byte{protocolVersion}); err != nil {
+		return "", err
+	}
+	peer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, peer); err != nil {
+		return "", err
+	}
+	if peer[0] != protocolVersion {
+		return "", fmt.Errorf("dosqlite: peer protocol version %d, want %d", peer[0], protocolVersion)
+	}
+
+	idLen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, idLen); err != nil {
+		return "", err
+	}
+	if idLen[0] == 0 {
+		return "", nil
+	}
+	idBytes := make([]byte, idLen[0])
+	if _, err := io.ReadFull(conn, idBytes); err != nil {
+		return "", err
+	}
+	return string(idBytes), nil
+}
+
+func (l *listenerConn) register(id uint64, p *pendingReq) {
+	l.pendingMu.Lock()
+	l.pending[id] = p
+	l.pendingMu.Unlock()
+	l.pendingCount.Add(1)
+}
+
+func (l *listenerConn) unregister(id uint64) {
+	l.pendingMu.Lock()
+	_, ok := l.pending[id]
+	delete(l.pending, id)
+	l.pendingMu.Unlock()
+	if ok {
+		l.pendingCount.Add(-1)
+	}
+}
+
+// failAll delivers err to every still-pending request, for use once the
+// connection is lost and no more responses will ever arrive. A request whose
+// frame was already written to the wire (p.written) gets err wrapped in
+// errAmbiguousWrite, since the backend may have already received and acted
+// on it before the connection failed.
+func (l *listenerConn) failAll(err error) {
+	l.pendingMu.Lock()
+	pending := l.pending
+	l.pending = make(map[uint64]*pendingReq)
+	l.pendingMu.Unlock()
+
+	for _, p := range pending {
+		l.pendingCount.Add(-1)
+		l.errorCount.Add(1)
+		deliverErr := err
+		if p.written.Load() {
+			deliverErr = &errAmbiguousWrite{err: err}
+		}
+		if p.stream != nil {
+			p.stream <- streamFrame{err: deliverErr}
+			close(p.stream)
+		} else {
+			p.respCh <- sendResp{err: deliverErr}
+		}
+	}
 }
 
-func (l *listenerConn) runLoop(ctx context.Context) {
+// markWritten records that id's frame has been fully written to the wire, so
+// a later connection failure is known to be ambiguous rather than safe to
+// retry elsewhere.
+func (l *listenerConn) markWritten(id uint64) {
+	l.pendingMu.Lock()
+	p, ok := l.pending[id]
+	l.pendingMu.Unlock()
+	if ok {
+		p.written.Store(true)
+	}
+}
+
+// writeLoop drains writeChan and writes each request's frame to the wire.
+// It's the only goroutine that writes to l.conn, so frames belonging to
+// different in-flight requests never interleave their bytes.
+func (l *listenerConn) writeLoop(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case msg := <-l.writeChan:
-			if err := l.send(msg); err != nil {
-				l.readChan <- sendResp{resp: "", err: err}
-				if err == io.EOF {
-					return
-				}
-				continue
+		case req := <-l.writeChan:
+			if err := l.writeFrame(req.id, req.msg); err != nil {
+				l.cancel(err)
+				l.failAll(err)
+				return
 			}
+			l.markWritten(req.id)
 		}
 	}
 }
 
-func (l *listenerConn) send(msg string) error {
-	// Write length-prefixed message
+// writeFrame writes msg as [uint32 length][uint64 requestID][payload],
+// where length covers the requestID and payload together.
+func (l *listenerConn) writeFrame(id uint64, msg string) error {
 	payload := []byte(msg)
+	body := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(body, id)
+	copy(body[8:], payload)
+
 	header := make([]byte, 4)
-	binary.BigEndian.PutUint32(header, uint32(len(payload)))
+	binary.BigEndian.PutUint32(header, uint32(len(body)))
 
 	if _, err := l.conn.Write(header); err != nil {
 		return err
 	}
-
-	if _, err := l.conn.Write(payload); err != nil {
+	if _, err := l.conn.Write(body); err != nil {
 		return err
 	}
+	return nil
+}
+
+// readLoop reads frames off the wire for as long as the connection is
+// alive, dispatching each to whichever pending request registered its
+// requestID, which lets multiple requests stay in flight on one connection
+// at a time instead of serializing one RTT per request. A frame whose ID
+// isn't pending (its caller's ctx already gave up on it) is dropped.
+func (l *listenerConn) readLoop(ctx context.Context) {
+	for {
+		id, payload, err := l.readFrame()
+		if err != nil {
+			l.cancel(err)
+			l.failAll(err)
+			return
+		}
+
+		l.pendingMu.Lock()
+		p, ok := l.pending[id]
+		done := ok && (p.stream == nil || p.isTerminal(payload))
+		if done {
+			delete(l.pending, id)
+		}
+		l.pendingMu.Unlock()
+		if done {
+			l.pendingCount.Add(-1)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if p.stream != nil {
+			p.stream <- streamFrame{data: payload}
+			if p.isTerminal(payload) {
+				l.sentCount.Add(1)
+				close(p.stream)
+			}
+		} else {
+			l.sentCount.Add(1)
+			p.respCh <- sendResp{resp: payload}
+		}
+	}
+}
 
-	// Read length-prefixed response
-	responseHeader := make([]byte, 4)
-	if _, err := io.ReadFull(l.conn, responseHeader); err != nil {
-		return err
+// readFrame reads one [uint32 length][uint64 requestID][payload] frame off
+// the wire and returns the requestID and payload separately.
+func (l *listenerConn) readFrame() (id uint64, payload string, err error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(l.conn, header); err != nil {
+		return 0, "", err
 	}
 
-	length := binary.BigEndian.Uint32(responseHeader)
-	responsePayload := make([]byte, length)
-	if _, err := io.ReadFull(l.conn, responsePayload); err != nil {
-		return err
+	length := binary.BigEndian.Uint32(header)
+	if length < 8 {
+		return 0, "", fmt.Errorf("dosqlite: frame too short: %d bytes", length)
 	}
 
-	l.readChan <- sendResp{resp: string(responsePayload), err: nil}
-	return nil
+	body := make([]byte, length)
+	if _, err := io.ReadFull(l.conn, body); err != nil {
+		return 0, "", err
+	}
+
+	return binary.BigEndian.Uint64(body[:8]), string(body[8:]), nil
 }
 
 func (l *listenerConn) Send(msg string) (resp string, err error) {
+	return l.SendContext(context.Background(), msg)
+}
+
+// SendContext tags msg with a fresh request ID and waits for the matching
+// response, failing early if ctx is done before it arrives. Other requests
+// can be sent and answered on the same connection while this one is
+// outstanding; the reader goroutine matches each response back to its
+// request ID. If ctx fires after the frame is already on the wire, the
+// worker is asked, via a best-effort "cancel" command naming this request's
+// ID, to interrupt specifically this request rather than whatever statement
+// happens to be running on the connection at that moment; the eventual
+// response to the original request, if any, is simply dropped by readLoop
+// once this request's ID is no longer pending.
+func (l *listenerConn) SendContext(ctx context.Context, msg string) (resp string, err error) {
 	if l.conn == nil {
 		return "", fmt.Errorf("no active connection")
 	}
 
+	id := l.nextID.Add(1)
+	respCh := make(chan sendResp, 1)
+	l.register(id, &pendingReq{respCh: respCh})
+
 	select {
-	case l.writeChan <- msg:
+	case l.writeChan <- writeReq{id: id, msg: msg}:
 	case <-l.ctx.Done():
+		l.unregister(id)
 		return "", context.Cause(l.ctx)
+	case <-ctx.Done():
+		l.unregister(id)
+		return "", ctx.Err()
 	}
 
 	select {
-	case r := <-l.readChan:
+	case r := <-respCh:
 		return r.resp, r.err
 	case <-l.ctx.Done():
 		return "", context.Cause(l.ctx)
+	case <-ctx.Done():
+		l.unregister(id)
+		go l.sendCancel(id)
+		return "", ctx.Err()
+	}
+}
+
+// SendStreamContext tags msg with a fresh request ID and returns a channel
+// of streamFrame that is fed, in order, with every frame the worker sends
+// back under that ID, until isTerminal reports the final frame has arrived
+// (at which point the channel is closed). The channel is also closed, with
+// a final error frame, if the connection is lost before the stream
+// completes.
+func (l *listenerConn) SendStreamContext(ctx context.Context, msg string, isTerminal func(frame string) bool) (<-chan streamFrame, error) {
+	if l.conn == nil {
+		return nil, fmt.Errorf("no active connection")
+	}
+
+	id := l.nextID.Add(1)
+	frames := make(chan streamFrame, 4)
+	l.register(id, &pendingReq{stream: frames, isTerminal: isTerminal})
+
+	select {
+	case l.writeChan <- writeReq{id: id, msg: msg}:
+	case <-l.ctx.Done():
+		l.unregister(id)
+		return nil, context.Cause(l.ctx)
+	case <-ctx.Done():
+		l.unregister(id)
+		return nil, ctx.Err()
+	}
+
+	return frames, nil
+}
+
+// sendCancel best-effort asks the worker to interrupt requestID specifically,
+// not whatever statement happens to be running on this connection: since
+// several requests can be in flight on one connection at once, an untargeted
+// interrupt could abort an unrelated sibling request whose context never
+// expired. Its response, if any, arrives under an ID nobody is waiting on and
+// is dropped by readLoop.
+func (l *listenerConn) sendCancel(requestID uint64) {
+	payload, err := json.Marshal(CancelRequest{Cmd: "cancel", RequestID: requestID})
+	if err != nil {
+		return
+	}
+	id := l.nextID.Add(1)
+	select {
+	case l.writeChan <- writeReq{id: id, msg: string(payload)}:
+	case <-l.ctx.Done():
 	}
 }
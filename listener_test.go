@@ -8,44 +8,96 @@ import (
 	"io"
 	"net"
 	"testing"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
-// mockClient simulates a client that responds to length-prefixed messages
+// doTestHandshake plays the client side of the handshake: read the
+// server's version byte, echo it back, then send a zero-length identity
+// (auto-assign), mirroring a worker that doesn't care which backend ID it
+// gets.
+func doTestHandshake(conn net.Conn) error {
+	return doTestHandshakeWithIdentity(conn, "")
+}
+
+// doTestHandshakeWithIdentity plays the client side of the handshake like
+// doTestHandshake, but supplies identity as the backend's requested ID
+// instead of auto-assigning one.
+func doTestHandshakeWithIdentity(conn net.Conn, identity string) error {
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return err
+	}
+	if _, err := conn.Write(version); err != nil {
+		return err
+	}
+
+	idBytes := []byte(identity)
+	if _, err := conn.Write([]byte{byte(len(idBytes))}); err != nil {
+		return err
+	}
+	if len(idBytes) == 0 {
+		return nil
+	}
+	_, err := conn.Write(idBytes)
+	return err
+}
+
+// readTestFrame reads one [uint32 length][uint64 requestID][payload] frame,
+// mirroring listenerConn.readFrame.
+func readTestFrame(conn net.Conn) (id uint64, payload string, err error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, "", err
+	}
+	length := binary.BigEndian.Uint32(header)
+	body := make([]byte, length)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, "", err
+	}
+	return binary.BigEndian.Uint64(body[:8]), string(body[8:]), nil
+}
+
+// writeTestFrame writes one [uint32 length][uint64 requestID][payload]
+// frame, mirroring listenerConn.writeFrame.
+func writeTestFrame(conn net.Conn, id uint64, payload string) error {
+	body := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(body, id)
+	copy(body[8:], payload)
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(body)))
+
+	if _, err := conn.Write(header); err != nil {
+		return err
+	}
+	_, err := conn.Write(body)
+	return err
+}
+
+// mockClient simulates a worker that echoes every request's payload back
+// under the same request ID
 func mockClient(t *testing.T, conn net.Conn) {
 	defer conn.Close()
 
+	if err := doTestHandshake(conn); err != nil {
+		t.Logf("Failed handshake: %v", err)
+		return
+	}
+
 	for {
-		// Read length-prefixed message
-		header := make([]byte, 4)
-		if _, err := io.ReadFull(conn, header); err != nil {
+		id, payload, err := readTestFrame(conn)
+		if err != nil {
 			if err == io.EOF || errors.Is(err, net.ErrClosed) {
 				return
 			}
-			t.Logf("Failed to read header: %v", err)
-			return
-		}
-
-		length := binary.BigEndian.Uint32(header)
-		payload := make([]byte, length)
-		if _, err := io.ReadFull(conn, payload); err != nil {
-			t.Logf("Failed to read payload: %v", err)
-			return
-		}
-
-		// Send length-prefixed response
-		responseBytes := []byte(payload)
-		responseHeader := make([]byte, 4)
-		binary.BigEndian.PutUint32(responseHeader, uint32(len(responseBytes)))
-
-		if _, err := conn.Write(responseHeader); err != nil {
-			t.Logf("Failed to write response header: %v", err)
+			t.Logf("Failed to read frame: %v", err)
 			return
 		}
 
-		if _, err := conn.Write(responseBytes); err != nil {
-			t.Logf("Failed to write response: %v", err)
+		if err := writeTestFrame(conn, id, payload); err != nil {
+			t.Logf("Failed to write frame: %v", err)
 			return
 		}
 	}
@@ -295,9 +347,9 @@ func TestListener_Close(t *testing.T) {
 	}
 
 	// Verify listener is removed from map
-	listenerConnsMu.RLock()
-	_, exists := listenerConns[listener.addr]
-	listenerConnsMu.RUnlock()
+	listenerPoolsMu.RLock()
+	_, exists := listenerPools[listener.addr]
+	listenerPoolsMu.RUnlock()
 
 	if exists {
 		t.Error("Listener still exists in map after close")
@@ -309,3 +361,210 @@ func TestListener_Close(t *testing.T) {
 		}
 	}
 }
+
+// silentClient completes the handshake and reads one request, but never
+// writes a response, so any Send against it must be released by its
+// context rather than a reply.
+func silentClient(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	if err := doTestHandshake(conn); err != nil {
+		t.Logf("Failed handshake: %v", err)
+		return
+	}
+	if _, _, err := readTestFrame(conn); err != nil {
+		return
+	}
+	<-make(chan struct{}) // block until the test closes the connection
+}
+
+// streamingClient simulates a worker that answers a single request with
+// multiple frames, all tagged with that request's ID, rather than one reply
+func streamingClient(t *testing.T, conn net.Conn, frames []string) {
+	defer conn.Close()
+
+	if err := doTestHandshake(conn); err != nil {
+		t.Logf("Failed handshake: %v", err)
+		return
+	}
+
+	id, _, err := readTestFrame(conn)
+	if err != nil {
+		t.Logf("Failed to read frame: %v", err)
+		return
+	}
+
+	for _, frame := range frames {
+		if err := writeTestFrame(conn, id, frame); err != nil {
+			t.Logf("Failed to write frame: %v", err)
+			return
+		}
+	}
+}
+
+func TestListener_SendStreamContext(t *testing.T) {
+	listener := AddListener("127.0.0.1:0")
+	defer listener.Close()
+
+	frames := []string{"chunk1", "chunk2", "done"}
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error {
+		return listener.Ready()
+	})
+	errg.Go(func() error {
+		conn, err := net.Dial("tcp", listener.addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		go streamingClient(t, conn, frames)
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	isTerminal := func(frame string) bool { return frame == "done" }
+	stream, err := listener.SendStreamContext(context.Background(), "query", isTerminal)
+	if err != nil {
+		t.Fatalf("Failed to send stream: %v", err)
+	}
+
+	var got []string
+	for f := range stream {
+		if f.err != nil {
+			t.Fatalf("unexpected frame error: %v", f.err)
+		}
+		got = append(got, f.data)
+	}
+
+	if len(got) != len(frames) {
+		t.Fatalf("expected %d frames, got %d: %v", len(frames), len(got), got)
+	}
+	for i, frame := range frames {
+		if got[i] != frame {
+			t.Errorf("frame %d: expected %q, got %q", i, frame, got[i])
+		}
+	}
+}
+
+func TestListener_SendContext_Timeout(t *testing.T) {
+	listener := AddListener("127.0.0.1:0")
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error {
+		return listener.Ready()
+	})
+	errg.Go(func() error {
+		conn, err := net.Dial("tcp", listener.addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		go silentClient(t, conn)
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := listener.SendContext(ctx, "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestListener_Pipelining asserts that a second request can be answered,
+// and its Send call can return, while an earlier request on the same
+// connection is still outstanding - the whole point of tagging frames with
+// a request ID instead of serializing one RTT per request.
+func TestListener_Pipelining(t *testing.T) {
+	listener := AddListener("127.0.0.1:0")
+	defer listener.Close()
+
+	gotSlow := make(chan struct{})
+	answeredFast := make(chan struct{})
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error {
+		return listener.Ready()
+	})
+	errg.Go(func() error {
+		conn, err := net.Dial("tcp", listener.addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		go func() {
+			defer conn.Close()
+			if err := doTestHandshake(conn); err != nil {
+				t.Errorf("handshake failed: %v", err)
+				return
+			}
+
+			slowID, _, err := readTestFrame(conn)
+			if err != nil {
+				t.Errorf("failed to read slow frame: %v", err)
+				return
+			}
+			close(gotSlow)
+
+			fastID, fastPayload, err := readTestFrame(conn)
+			if err != nil {
+				t.Errorf("failed to read fast frame: %v", err)
+				return
+			}
+			if err := writeTestFrame(conn, fastID, fastPayload); err != nil {
+				t.Errorf("failed to answer fast frame: %v", err)
+				return
+			}
+			close(answeredFast)
+
+			time.Sleep(20 * time.Millisecond)
+			if err := writeTestFrame(conn, slowID, "slow-done"); err != nil {
+				t.Errorf("failed to answer slow frame: %v", err)
+			}
+		}()
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	slowDone := make(chan struct{})
+	var slowResp string
+	var slowErr error
+	go func() {
+		slowResp, slowErr = listener.Send("slow")
+		close(slowDone)
+	}()
+
+	<-gotSlow
+
+	fastResp, err := listener.Send("fast")
+	if err != nil {
+		t.Fatalf("fast send failed: %v", err)
+	}
+	if fastResp != "fast" {
+		t.Errorf("expected 'fast', got %q", fastResp)
+	}
+
+	select {
+	case <-answeredFast:
+	case <-time.After(time.Second):
+		t.Fatal("fast frame was never answered")
+	}
+
+	select {
+	case <-slowDone:
+	case <-time.After(time.Second):
+		t.Fatal("slow send never completed")
+	}
+	if slowErr != nil {
+		t.Fatalf("slow send failed: %v", slowErr)
+	}
+	if slowResp != "slow-done" {
+		t.Errorf("expected 'slow-done', got %q", slowResp)
+	}
+}
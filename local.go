@@ -0,0 +1,41 @@
+package dosqlite
+
+import (
+	"context"
+	"sync"
+)
+
+// localHandlers holds every address registered via RegisterLocal, keyed by
+// the same addr a dosqlite:// DSN's host would otherwise dial over TCP.
+var (
+	localHandlersMu sync.RWMutex
+	localHandlers   = make(map[string]func(ctx context.Context, msg string) (string, error))
+)
+
+// RegisterLocal registers handler as the in-process backend for addr,
+// mirroring rqlite's Client.SetLocal: a Driver.Open whose DSN host matches
+// addr calls handler directly instead of dialing a TCP listener, which lets
+// a single-binary deployment (or a test) skip loopback networking entirely
+// when the backend logic already lives in the same process. handler is
+// called synchronously for every request and must honor ctx and be safe
+// for concurrent use, since concurrent callers on the same or different
+// connections may invoke it at once.
+func RegisterLocal(addr string, handler func(ctx context.Context, msg string) (string, error)) {
+	localHandlersMu.Lock()
+	defer localHandlersMu.Unlock()
+	localHandlers[addr] = handler
+}
+
+// UnregisterLocal removes a handler previously registered via RegisterLocal.
+func UnregisterLocal(addr string) {
+	localHandlersMu.Lock()
+	defer localHandlersMu.Unlock()
+	delete(localHandlers, addr)
+}
+
+func getLocalHandler(addr string) (func(ctx context.Context, msg string) (string, error), bool) {
+	localHandlersMu.RLock()
+	defer localHandlersMu.RUnlock()
+	h, ok := localHandlers[addr]
+	return h, ok
+}
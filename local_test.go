@@ -0,0 +1,277 @@
+package dosqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestRegisterLocal(t *testing.T) {
+	const addr = "local-test-1"
+
+	RegisterLocal(addr, func(ctx context.Context, msg string) (string, error) {
+		var req ExecRequest
+		if err := json.Unmarshal([]byte(msg), &req); err != nil {
+			return "", err
+		}
+		resp, _ := json.Marshal(ExecResponse{OK: true, LastInsertRowID: 7})
+		return string(resp), nil
+	})
+	defer UnregisterLocal(addr)
+
+	d := &Driver{}
+	conn, err := d.Open("dosqlite://" + addr)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer conn.Close()
+
+	c := conn.(*Conn)
+	result, err := c.ExecContext(context.Background(), "insert into t values (1)", nil)
+	if err != nil {
+		t.Fatalf("ExecContext failed: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("LastInsertId failed: %v", err)
+	}
+	if id != 7 {
+		t.Errorf("expected LastInsertId 7, got %d", id)
+	}
+}
+
+func TestRegisterLocal_Query(t *testing.T) {
+	const addr = "local-test-2"
+
+	RegisterLocal(addr, func(ctx context.Context, msg string) (string, error) {
+		frame, _ := json.Marshal(QueryStreamFrame{
+			Columns:     []string{"id"},
+			ColumnTypes: []string{"INTEGER"},
+			Chunk:       [][]interface{}{{JSONValue{Type: "int", V: "1"}}},
+			Done:        true,
+		})
+		return string(frame), nil
+	})
+	defer UnregisterLocal(addr)
+
+	d := &Driver{}
+	conn, err := d.Open("dosqlite://" + addr)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer conn.Close()
+
+	c := conn.(*Conn)
+	rows, err := c.QueryContext(context.Background(), "select id from t", nil)
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	defer rows.Close()
+
+	if cols := rows.Columns(); len(cols) != 1 || cols[0] != "id" {
+		t.Fatalf("expected columns [id], got %v", cols)
+	}
+
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if dest[0] != int64(1) {
+		t.Errorf("expected row value 1, got %v", dest[0])
+	}
+
+	if err := rows.Next(dest); err != io.EOF {
+		t.Errorf("expected io.EOF after last row, got %v", err)
+	}
+}
+
+func TestRegisterLocal_Transaction(t *testing.T) {
+	const addr = "local-test-4"
+
+	var (
+		committed  []string
+		rolledBack []string
+	)
+
+	RegisterLocal(addr, func(ctx context.Context, msg string) (string, error) {
+		var cmd struct {
+			Cmd string `json:"cmd"`
+		}
+		if err := json.Unmarshal([]byte(msg), &cmd); err != nil {
+			return "", err
+		}
+
+		switch cmd.Cmd {
+		case "begin":
+			resp, _ := json.Marshal(BeginResponse{OK: true, TxID: "tx-1"})
+			return string(resp), nil
+		case "commit":
+			var req CommitRequest
+			_ = json.Unmarshal([]byte(msg), &req)
+			committed = append(committed, req.TxID)
+			resp, _ := json.Marshal(CommitResponse{OK: true})
+			return string(resp), nil
+		case "rollback":
+			var req RollbackRequest
+			_ = json.Unmarshal([]byte(msg), &req)
+			rolledBack = append(rolledBack, req.TxID)
+			resp, _ := json.Marshal(RollbackResponse{OK: true})
+			return string(resp), nil
+		default:
+			return "", fmt.Errorf("unexpected cmd %q", cmd.Cmd)
+		}
+	})
+	defer UnregisterLocal(addr)
+
+	d := &Driver{}
+	conn, err := d.Open("dosqlite://" + addr)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer conn.Close()
+
+	c := conn.(*Conn)
+
+	tx, err := c.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if c.txID != "tx-1" {
+		t.Fatalf("expected txID tx-1 after Begin, got %q", c.txID)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if c.txID != "" {
+		t.Errorf("expected txID cleared after Commit, got %q", c.txID)
+	}
+	if len(committed) != 1 || committed[0] != "tx-1" {
+		t.Errorf("expected commit of tx-1, got %v", committed)
+	}
+
+	tx, err = c.Begin()
+	if err != nil {
+		t.Fatalf("second Begin failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if c.txID != "" {
+		t.Errorf("expected txID cleared after Rollback, got %q", c.txID)
+	}
+	if len(rolledBack) != 1 || rolledBack[0] != "tx-1" {
+		t.Errorf("expected rollback of tx-1, got %v", rolledBack)
+	}
+}
+
+func TestRegisterLocal_PreparedStatement(t *testing.T) {
+	const addr = "local-test-5"
+
+	var closed []string
+
+	RegisterLocal(addr, func(ctx context.Context, msg string) (string, error) {
+		var cmd struct {
+			Cmd string `json:"cmd"`
+		}
+		if err := json.Unmarshal([]byte(msg), &cmd); err != nil {
+			return "", err
+		}
+
+		switch cmd.Cmd {
+		case "prepare":
+			resp, _ := json.Marshal(PrepareResponse{OK: true, StmtID: "stmt-1"})
+			return string(resp), nil
+		case "exec_stmt":
+			resp, _ := json.Marshal(ExecResponse{OK: true, LastInsertRowID: 42, Changes: 1})
+			return string(resp), nil
+		case "query_stmt":
+			frame, _ := json.Marshal(QueryStreamFrame{
+				Columns:     []string{"id"},
+				ColumnTypes: []string{"INTEGER"},
+				Chunk:       [][]interface{}{{JSONValue{Type: "int", V: "9"}}},
+				Done:        true,
+			})
+			return string(frame), nil
+		case "close_stmt":
+			var req CloseStmtRequest
+			_ = json.Unmarshal([]byte(msg), &req)
+			closed = append(closed, req.StmtID)
+			resp, _ := json.Marshal(CloseStmtResponse{OK: true})
+			return string(resp), nil
+		default:
+			return "", fmt.Errorf("unexpected cmd %q", cmd.Cmd)
+		}
+	})
+	defer UnregisterLocal(addr)
+
+	d := &Driver{}
+	conn, err := d.Open("dosqlite://" + addr)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer conn.Close()
+
+	c := conn.(*Conn)
+	driverStmt, err := c.Prepare("insert into t values (?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	stmt := driverStmt.(*Stmt)
+	if stmt.stmtID != "stmt-1" {
+		t.Fatalf("expected stmtID stmt-1, got %q", stmt.stmtID)
+	}
+
+	result, err := stmt.ExecContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: int64(1)}})
+	if err != nil {
+		t.Fatalf("ExecContext failed: %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 42 {
+		t.Errorf("expected LastInsertId 42, got %d", id)
+	}
+
+	rows, err := stmt.QueryContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: int64(1)}})
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if dest[0] != int64(9) {
+		t.Errorf("expected row value 9, got %v", dest[0])
+	}
+	rows.Close()
+
+	if err := stmt.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(closed) != 1 || closed[0] != "stmt-1" {
+		t.Errorf("expected close_stmt for stmt-1, got %v", closed)
+	}
+}
+
+func TestRegisterLocal_PropagatesError(t *testing.T) {
+	const addr = "local-test-3"
+	wantErr := errors.New("boom")
+
+	RegisterLocal(addr, func(ctx context.Context, msg string) (string, error) {
+		return "", wantErr
+	})
+	defer UnregisterLocal(addr)
+
+	d := &Driver{}
+	conn, err := d.Open("dosqlite://" + addr)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer conn.Close()
+
+	c := conn.(*Conn)
+	if _, err := c.ExecContext(context.Background(), "insert into t values (1)", nil); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+}
@@ -0,0 +1,267 @@
+package migrate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// This file provides a minimal database/sql/driver backing an in-memory
+// *sql.DB, so migrate_test.go can drive Up/Down/Version against a real
+// *sql.DB without depending on a real SQL engine. It understands only the
+// handful of statements migrate.go itself issues (schema_migrations
+// bookkeeping) plus the small set of CREATE/DROP TABLE and "FAIL" sentinel
+// statements the tests use as migration bodies.
+
+var (
+	fakeStoresMu sync.Mutex
+	fakeStores   = map[string]*fakeStore{}
+)
+
+func init() {
+	sql.Register("migrate-fake", fakeDriver{})
+}
+
+// newFakeDB registers a fresh, empty fakeStore under name and opens a
+// *sql.DB against it, cleaning the registration up when t is done.
+func newFakeDB(t interface{ Cleanup(func()) }, name string) *sql.DB {
+	fakeStoresMu.Lock()
+	fakeStores[name] = &fakeStore{migrations: map[uint]bool{}, tables: map[string]bool{}}
+	fakeStoresMu.Unlock()
+	t.Cleanup(func() {
+		fakeStoresMu.Lock()
+		delete(fakeStores, name)
+		fakeStoresMu.Unlock()
+	})
+
+	db, err := sql.Open("migrate-fake", name)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// fakeStore is the state behind one fake *sql.DB, shared by every fakeConn
+// opened against the same name so a connection-pooled *sql.DB sees
+// consistent state regardless of which conn serves a given call.
+type fakeStore struct {
+	mu         sync.Mutex
+	migrations map[uint]bool
+	tables     map[string]bool
+}
+
+// fakeState is a point-in-time copy of a fakeStore's maps, used to give a
+// transaction isolated writes that are only folded back into the store on
+// Commit, so a Rollback (triggered by a failing migration) leaves the
+// store exactly as it was.
+type fakeState struct {
+	migrations map[uint]bool
+	tables     map[string]bool
+}
+
+func copyUintSet(m map[uint]bool) map[uint]bool {
+	out := make(map[uint]bool, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyStringSet(m map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+type fakeDriver struct{}
+
+var _ driver.Driver = fakeDriver{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeStoresMu.Lock()
+	store, ok := fakeStores[name]
+	fakeStoresMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("migrate: no fake store registered for %q", name)
+	}
+	return &fakeConn{store: store}, nil
+}
+
+// fakeConn is a driver.Conn (and, while a transaction is open, also the
+// driver.Tx returned by Begin) backed by a fakeStore.
+type fakeConn struct {
+	store *fakeStore
+	tx    *fakeState
+}
+
+var (
+	_ driver.Conn = &fakeConn{}
+	_ driver.Tx   = &fakeConn{}
+)
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: strings.TrimSpace(query)}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, fmt.Errorf("migrate: nested transactions unsupported")
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.tx = &fakeState{
+		migrations: copyUintSet(c.store.migrations),
+		tables:     copyStringSet(c.store.tables),
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	if c.tx == nil {
+		return fmt.Errorf("migrate: commit without transaction")
+	}
+	c.store.mu.Lock()
+	c.store.migrations = c.tx.migrations
+	c.store.tables = c.tx.tables
+	c.store.mu.Unlock()
+	c.tx = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.tx = nil
+	return nil
+}
+
+// fakeStmt interprets just the statements migrate.go (and the tests'
+// migration fixtures) actually issue.
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+var (
+	_ driver.Stmt = &fakeStmt{}
+)
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.conn.tx == nil {
+		s.conn.store.mu.Lock()
+		defer s.conn.store.mu.Unlock()
+	}
+	migrations, tables := s.maps()
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		// The maps already exist; nothing to do.
+	case strings.HasPrefix(s.query, "INSERT INTO schema_migrations"):
+		migrations[uint(args[0].(int64))] = true
+	case strings.HasPrefix(s.query, "DELETE FROM schema_migrations"):
+		delete(migrations, uint(args[0].(int64)))
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		name := tableName(s.query)
+		if tables[name] {
+			return nil, fmt.Errorf("migrate: table %s already exists", name)
+		}
+		tables[name] = true
+	case strings.HasPrefix(s.query, "DROP TABLE"):
+		name := tableName(s.query)
+		if !tables[name] {
+			return nil, fmt.Errorf("migrate: no such table: %s", name)
+		}
+		delete(tables, name)
+	case strings.TrimSpace(s.query) == "FAIL":
+		return nil, fmt.Errorf("migrate: simulated failure")
+	default:
+		return nil, fmt.Errorf("migrate: fake driver does not understand %q", s.query)
+	}
+
+	return fakeResult{}, nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.conn.tx == nil {
+		s.conn.store.mu.Lock()
+		defer s.conn.store.mu.Unlock()
+	}
+	migrations, _ := s.maps()
+
+	switch {
+	case strings.HasPrefix(s.query, "SELECT MAX(version)"):
+		if len(migrations) == 0 {
+			return &fakeRows{columns: []string{"MAX(version)"}, rows: [][]driver.Value{{nil}}}, nil
+		}
+		var max int64 = -1
+		for v := range migrations {
+			if int64(v) > max {
+				max = int64(v)
+			}
+		}
+		return &fakeRows{columns: []string{"MAX(version)"}, rows: [][]driver.Value{{max}}}, nil
+	case strings.HasPrefix(s.query, "SELECT version FROM schema_migrations"):
+		rows := make([][]driver.Value, 0, len(migrations))
+		for v := range migrations {
+			rows = append(rows, []driver.Value{int64(v)})
+		}
+		return &fakeRows{columns: []string{"version"}, rows: rows}, nil
+	default:
+		return nil, fmt.Errorf("migrate: fake driver does not understand query %q", s.query)
+	}
+}
+
+// maps returns the transaction's isolated maps if one is open, or the
+// store's own maps otherwise. The caller is responsible for holding
+// s.conn.store.mu when operating directly on the store's maps.
+func (s *fakeStmt) maps() (map[uint]bool, map[string]bool) {
+	if s.conn.tx != nil {
+		return s.conn.tx.migrations, s.conn.tx.tables
+	}
+	return s.conn.store.migrations, s.conn.store.tables
+}
+
+// tableName pulls the table name out of a "CREATE TABLE <name> (...)" or
+// "DROP TABLE <name>" statement.
+func tableName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+type fakeResult struct{}
+
+var _ driver.Result = fakeResult{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+var _ driver.Rows = &fakeRows{}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package migrate provides a small schema migration runner for dosqlite, in
+// the spirit of goose and mattes-migrate. It reads a directory of numbered
+// SQL files and tracks which have been applied in a schema_migrations table,
+// since a remote Durable-Object-backed SQLite database has no other channel
+// for schema evolution.
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migration represents a single numbered schema change, loaded from a pair
+// of up/down SQL files.
+type migration struct {
+	version uint
+	name    string
+	up      string
+	down    string
+}
+
+var filenameRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Up applies all migrations in fsys that have not yet been applied to db, in
+// version order. Each migration runs inside its own transaction so a partial
+// failure rolls back cleanly.
+func Up(db *sql.DB, fsys fs.FS) error {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureSchemaMigrationsTable(db); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := runInTx(db, m.up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("dosqlite/migrate: applying migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Down rolls back the given number of applied migrations, most recent first.
+// A steps of 0 is a no-op; a negative steps rolls back every applied
+// migration.
+func Down(db *sql.DB, fsys fs.FS, steps int) error {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureSchemaMigrationsTable(db); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	// Walk migrations newest-first so rollbacks undo in reverse order.
+	for i := len(migrations) - 1; i >= 0 && steps != 0; i-- {
+		m := migrations[i]
+		if !applied[m.version] {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf("dosqlite/migrate: migration %d_%s has no down file", m.version, m.name)
+		}
+		if err := runInTx(db, m.down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("dosqlite/migrate: rolling back migration %d_%s: %w", m.version, m.name, err)
+		}
+		if steps > 0 {
+			steps--
+		}
+	}
+
+	return nil
+}
+
+// Version returns the highest applied migration version and whether any
+// migrations have been applied at all.
+func Version(db *sql.DB) (uint, bool, error) {
+	if err := ensureSchemaMigrationsTable(db); err != nil {
+		return 0, false, err
+	}
+
+	var version sql.NullInt64
+	row := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`)
+	if err := row.Scan(&version); err != nil {
+		return 0, false, fmt.Errorf("dosqlite/migrate: reading current version: %w", err)
+	}
+
+	if !version.Valid {
+		return 0, false, nil
+	}
+	return uint(version.Int64), true, nil
+}
+
+func ensureSchemaMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("dosqlite/migrate: creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func appliedVersions(db *sql.DB) (map[uint]bool, error) {
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("dosqlite/migrate: reading applied versions: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[uint]bool{}
+	for rows.Next() {
+		var version uint
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("dosqlite/migrate: scanning applied version: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// runInTx runs sql inside a transaction, then calls after (used to record or
+// unrecord the migration's version) before committing. On any error the
+// transaction is rolled back.
+func runInTx(db *sql.DB, sqlText string, after func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	if _, err := tx.Exec(sqlText); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("executing migration: %w", err)
+	}
+
+	if err := after(tx); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("recording migration version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// loadMigrations reads fsys for pairs of <version>_<name>.up.sql /
+// <version>_<name>.down.sql files and returns them sorted by version.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("dosqlite/migrate: reading migrations directory: %w", err)
+	}
+
+	byVersion := map[uint]*migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := filenameRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("dosqlite/migrate: invalid version in %q: %w", entry.Name(), err)
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("dosqlite/migrate: reading %q: %w", entry.Name(), err)
+		}
+
+		m := byVersion[uint(version)]
+		if m == nil {
+			m = &migration{version: uint(version), name: match[2]}
+			byVersion[uint(version)] = m
+		}
+
+		switch match[3] {
+		case "up":
+			m.up = string(contents)
+		case "down":
+			m.down = string(contents)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
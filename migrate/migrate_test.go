@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_init.up.sql":       {Data: []byte("CREATE TABLE foo (id INTEGER PRIMARY KEY)")},
+		"001_init.down.sql":     {Data: []byte("DROP TABLE foo")},
+		"002_add_name.up.sql":   {Data: []byte("ALTER TABLE foo ADD COLUMN name TEXT")},
+		"002_add_name.down.sql": {Data: []byte("ALTER TABLE foo DROP COLUMN name")},
+		"not_a_migration.sql":   {Data: []byte("SELECT 1")},
+		"003_no_down.up.sql":    {Data: []byte("CREATE TABLE bar (id INTEGER PRIMARY KEY)")},
+	}
+
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+
+	if len(migrations) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(migrations))
+	}
+
+	if migrations[0].version != 1 || migrations[0].name != "init" {
+		t.Errorf("migrations[0] = %+v, want version 1 name init", migrations[0])
+	}
+	if migrations[0].up == "" || migrations[0].down == "" {
+		t.Errorf("migrations[0] missing up/down SQL: %+v", migrations[0])
+	}
+
+	if migrations[1].version != 2 || migrations[1].name != "add_name" {
+		t.Errorf("migrations[1] = %+v, want version 2 name add_name", migrations[1])
+	}
+
+	if migrations[2].version != 3 || migrations[2].down != "" {
+		t.Errorf("migrations[2] = %+v, want version 3 with no down SQL", migrations[2])
+	}
+}
+
+func TestUp_SkipsAlreadyApplied(t *testing.T) {
+	db := newFakeDB(t, "TestUp_SkipsAlreadyApplied")
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER)")},
+		"001_widgets.down.sql": {Data: []byte("DROP TABLE widgets")},
+		"002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER)")},
+		"002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets")},
+	}
+
+	if err := Up(db, fsys); err != nil {
+		t.Fatalf("first Up() error = %v", err)
+	}
+	version, applied, err := Version(db)
+	if err != nil || !applied || version != 2 {
+		t.Fatalf("after first Up(): version=%d applied=%v err=%v, want 2 true <nil>", version, applied, err)
+	}
+
+	// Re-running Up() must not try to re-create widgets/gadgets, which
+	// would fail since the fake driver's CREATE TABLE isn't idempotent.
+	if err := Up(db, fsys); err != nil {
+		t.Fatalf("second Up() error = %v", err)
+	}
+	version, applied, err = Version(db)
+	if err != nil || !applied || version != 2 {
+		t.Fatalf("after second Up(): version=%d applied=%v err=%v, want 2 true <nil>", version, applied, err)
+	}
+}
+
+func TestUp_RollsBackOnFailure(t *testing.T) {
+	db := newFakeDB(t, "TestUp_RollsBackOnFailure")
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_widgets.up.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER)")},
+		"002_broken.up.sql":  {Data: []byte("FAIL")},
+	}
+
+	err := Up(db, fsys)
+	if err == nil {
+		t.Fatal("Up() with a failing migration: expected error, got nil")
+	}
+
+	version, applied, err := Version(db)
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if !applied || version != 1 {
+		t.Fatalf("version=%d applied=%v, want 1 true (migration 1 committed, migration 2 rolled back)", version, applied)
+	}
+}
+
+func TestDown_ReverseOrderWithSteps(t *testing.T) {
+	db := newFakeDB(t, "TestDown_ReverseOrderWithSteps")
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER)")},
+		"001_widgets.down.sql": {Data: []byte("DROP TABLE widgets")},
+		"002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER)")},
+		"002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets")},
+		"003_gizmos.up.sql":    {Data: []byte("CREATE TABLE gizmos (id INTEGER)")},
+		"003_gizmos.down.sql":  {Data: []byte("DROP TABLE gizmos")},
+	}
+
+	if err := Up(db, fsys); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	// Down(1) should only undo the most recently applied migration (3),
+	// leaving 1 and 2 applied.
+	if err := Down(db, fsys, 1); err != nil {
+		t.Fatalf("Down(1) error = %v", err)
+	}
+	version, _, err := Version(db)
+	if err != nil || version != 2 {
+		t.Fatalf("after Down(1): version=%d err=%v, want 2", version, err)
+	}
+
+	// A negative steps rolls back everything still applied.
+	if err := Down(db, fsys, -1); err != nil {
+		t.Fatalf("Down(-1) error = %v", err)
+	}
+	_, applied, err := Version(db)
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if applied {
+		t.Fatal("after Down(-1): expected no migrations applied")
+	}
+}
+
+func TestDown_NoDownFileError(t *testing.T) {
+	db := newFakeDB(t, "TestDown_NoDownFileError")
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_widgets.up.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER)")},
+	}
+
+	if err := Up(db, fsys); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	err := Down(db, fsys, 1)
+	if err == nil {
+		t.Fatal("Down() on a migration with no down file: expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "no down file") {
+		t.Errorf("Down() error = %v, want it to mention 'no down file'", err)
+	}
+}
+
+func TestVersion_NoMigrationsApplied(t *testing.T) {
+	db := newFakeDB(t, "TestVersion_NoMigrationsApplied")
+	defer db.Close()
+
+	version, applied, err := Version(db)
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if applied || version != 0 {
+		t.Errorf("Version() = %d, %v, want 0, false", version, applied)
+	}
+}
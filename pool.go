@@ -0,0 +1,312 @@
+package dosqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// Strategy selects how a listener spreads Send across the backend
+// connections registered to its address.
+type Strategy int
+
+const (
+	// StrategyExclusive is dosqlite's original behavior: exactly one
+	// backend serves the address at a time, and a new connection
+	// immediately replaces whatever backend came before it.
+	StrategyExclusive Strategy = iota
+	// StrategyRoundRobin cycles through healthy backends in turn.
+	StrategyRoundRobin
+	// StrategyLeastOutstanding sends each request to whichever healthy
+	// backend currently has the fewest requests in flight.
+	StrategyLeastOutstanding
+)
+
+// ListenerOptions configures AddListenerWithOptions.
+type ListenerOptions struct {
+	// MaxBackends caps how many simultaneous backend connections the
+	// listener accepts; 0 means unlimited. Ignored by StrategyExclusive,
+	// which always keeps exactly one.
+	MaxBackends int
+	// Strategy selects how Send picks among registered backends.
+	Strategy Strategy
+}
+
+// listenerOptionsFromQuery parses a DSN's "max_backends" and "strategy"
+// query parameters into ListenerOptions, so Driver.Open can create a
+// multi-backend pool directly from a dosqlite:// DSN instead of every
+// caller that wants one having to reach for AddListenerWithOptions
+// themselves. strategy accepts "exclusive" (the default), "round_robin",
+// and "least_outstanding".
+func listenerOptionsFromQuery(q url.Values) (ListenerOptions, error) {
+	var opts ListenerOptions
+
+	if s := q.Get("max_backends"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return opts, fmt.Errorf("dosqlite: invalid max_backends: %w", err)
+		}
+		opts.MaxBackends = n
+	}
+
+	switch s := q.Get("strategy"); s {
+	case "", "exclusive":
+		opts.Strategy = StrategyExclusive
+	case "round_robin":
+		opts.Strategy = StrategyRoundRobin
+	case "least_outstanding":
+		opts.Strategy = StrategyLeastOutstanding
+	default:
+		return opts, fmt.Errorf("dosqlite: invalid strategy %q", s)
+	}
+
+	return opts, nil
+}
+
+// BackendInfo is a point-in-time snapshot of one backend connection's
+// identity and traffic, as reported by listener.Backends.
+type BackendInfo struct {
+	ID      string
+	Pending int64
+	Sent    int64
+	Errors  int64
+}
+
+// pool holds every backend connection currently registered to a listener's
+// address and implements the Strategy it was created with. Ready/Next
+// callers wait on ctx, which is replaced with a fresh one every time the
+// backend set changes, so the old one can be cancelled to wake them.
+type pool struct {
+	opts ListenerOptions
+
+	mu       sync.RWMutex
+	backends map[string]*listenerConn
+	order    []string // insertion order, used for round robin
+
+	rrNext int
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func newPool(opts ListenerOptions) *pool {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &pool{
+		opts:     opts,
+		backends: make(map[string]*listenerConn),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (p *pool) ready() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.order) > 0
+}
+
+func (p *pool) currentCtx() context.Context {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ctx
+}
+
+// bumpGeneration replaces p.ctx/p.cancel and returns the old cancel func, so
+// the caller can cancel it once the backend set change it guards is
+// visible, waking any Ready/Next callers blocked on the old one.
+func (p *pool) bumpGeneration() context.CancelCauseFunc {
+	old := p.cancel
+	p.ctx, p.cancel = context.WithCancelCause(context.Background())
+	return old
+}
+
+// add registers a newly handshaken backend under id, evicting whatever
+// backend(s) the pool's Strategy says must make way for it.
+func (p *pool) add(id string, lc *listenerConn) {
+	p.mu.Lock()
+	var evicted []*listenerConn
+
+	switch {
+	case p.opts.Strategy == StrategyExclusive:
+		for _, old := range p.backends {
+			evicted = append(evicted, old)
+		}
+		p.backends = make(map[string]*listenerConn)
+		p.order = nil
+	case p.opts.MaxBackends > 0 && len(p.order) >= p.opts.MaxBackends:
+		oldest := p.order[0]
+		evicted = append(evicted, p.backends[oldest])
+		delete(p.backends, oldest)
+		p.order = p.order[1:]
+	}
+
+	if old, ok := p.backends[id]; ok {
+		evicted = append(evicted, old)
+	} else {
+		p.order = append(p.order, id)
+	}
+	p.backends[id] = lc
+
+	cancel := p.bumpGeneration()
+	p.mu.Unlock()
+
+	cancel(nil)
+	for _, e := range evicted {
+		e.cancel(nil)
+		_ = e.conn.Close()
+	}
+}
+
+// remove drops a backend once its connection is known to be gone. lc is
+// passed in, not just id, so a backend that was already replaced (and whose
+// id a new connection has since reused) isn't removed twice.
+func (p *pool) remove(id string, lc *listenerConn) {
+	p.mu.Lock()
+	if p.backends[id] != lc {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.backends, id)
+	for i, existing := range p.order {
+		if existing == id {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	cancel := p.bumpGeneration()
+	p.mu.Unlock()
+
+	cancel(fmt.Errorf("dosqlite: backend %q disconnected", id))
+}
+
+// watch removes lc from the pool as soon as its connection is lost.
+func (p *pool) watch(id string, lc *listenerConn) {
+	<-lc.ctx.Done()
+	p.remove(id, lc)
+}
+
+// closeAll tears down every backend and wakes any Ready/Next waiters with
+// context.Canceled, for use when the listener itself is closed.
+func (p *pool) closeAll() {
+	p.mu.Lock()
+	backends := p.backends
+	p.backends = make(map[string]*listenerConn)
+	p.order = nil
+	cancel := p.bumpGeneration()
+	p.mu.Unlock()
+
+	cancel(nil)
+	for _, lc := range backends {
+		lc.cancel(nil)
+		_ = lc.conn.Close()
+	}
+}
+
+// pickExcluding selects a healthy backend according to the pool's Strategy,
+// skipping anything in exclude, and also returns the total number of
+// registered backends so a caller retrying across them knows when it has
+// exhausted every option.
+func (p *pool) pickExcluding(exclude map[*listenerConn]bool) (lc *listenerConn, total int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total = len(p.order)
+	if total == 0 {
+		return nil, 0, fmt.Errorf("no active connection")
+	}
+
+	candidates := make([]string, 0, total)
+	for _, id := range p.order {
+		if !exclude[p.backends[id]] {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, total, fmt.Errorf("dosqlite: no healthy backend available")
+	}
+
+	if p.opts.Strategy == StrategyLeastOutstanding {
+		best := candidates[0]
+		bestPending := p.backends[best].pendingCount.Load()
+		for _, id := range candidates[1:] {
+			if pending := p.backends[id].pendingCount.Load(); pending < bestPending {
+				best, bestPending = id, pending
+			}
+		}
+		return p.backends[best], total, nil
+	}
+
+	// StrategyExclusive and StrategyRoundRobin both cycle through
+	// whatever's left; Exclusive only ever has one candidate.
+	id := candidates[p.rrNext%len(candidates)]
+	p.rrNext++
+	return p.backends[id], total, nil
+}
+
+// sendContext picks a healthy backend and sends msg, retrying on a
+// different backend if the chosen one fails for a reason other than ctx
+// itself giving up.
+func (p *pool) sendContext(ctx context.Context, msg string) (string, error) {
+	attempted := map[*listenerConn]bool{}
+	for {
+		lc, total, err := p.pickExcluding(attempted)
+		if err != nil {
+			return "", err
+		}
+		attempted[lc] = true
+
+		resp, err := lc.SendContext(ctx, msg)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", err
+		}
+		var ambiguous *errAmbiguousWrite
+		if errors.As(err, &ambiguous) {
+			// The request's frame was already written to the wire before the
+			// connection failed, so the backend may have already acted on it.
+			// Retrying a non-idempotent command (exec, commit, ...) against a
+			// different backend risks silently duplicating its side effects,
+			// so surface the failure instead of retrying.
+			return "", ambiguous.err
+		}
+		if len(attempted) >= total {
+			return "", err
+		}
+	}
+}
+
+// sendStreamContext picks a healthy backend and opens a streamed exchange
+// against it. Unlike sendContext, a mid-stream failure isn't retried: once
+// rows have started arriving there's no way to resume a partial result on a
+// different backend.
+func (p *pool) sendStreamContext(ctx context.Context, msg string, isTerminal func(frame string) bool) (<-chan streamFrame, error) {
+	lc, _, err := p.pickExcluding(nil)
+	if err != nil {
+		return nil, err
+	}
+	return lc.SendStreamContext(ctx, msg, isTerminal)
+}
+
+// snapshot reports the current identity and traffic of every registered
+// backend, in the order they were added.
+func (p *pool) snapshot() []BackendInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	infos := make([]BackendInfo, 0, len(p.order))
+	for _, id := range p.order {
+		lc := p.backends[id]
+		infos = append(infos, BackendInfo{
+			ID:      id,
+			Pending: lc.pendingCount.Load(),
+			Sent:    lc.sentCount.Load(),
+			Errors:  lc.errorCount.Load(),
+		})
+	}
+	return infos
+}
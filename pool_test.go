@@ -0,0 +1,250 @@
+package dosqlite
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// dialAndHandshake connects to addr, completes the handshake under the
+// given identity (empty for auto-assign), and returns the raw connection
+// for the caller to drive as a mock worker.
+func dialAndHandshake(t *testing.T, addr, identity string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err := doTestHandshakeWithIdentity(conn, identity); err != nil {
+		t.Fatalf("Failed handshake: %v", err)
+	}
+	return conn
+}
+
+func TestListener_RoundRobin(t *testing.T) {
+	listener := AddListenerWithOptions("127.0.0.1:0", ListenerOptions{Strategy: StrategyRoundRobin})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error { return listener.Ready() })
+	errg.Go(func() error {
+		conn := dialAndHandshake(t, listener.addr, "one")
+		go mockEchoWithID(t, conn, "one")
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	conn2 := dialAndHandshake(t, listener.addr, "two")
+	go mockEchoWithID(t, conn2, "two")
+
+	// Wait until the second backend is actually registered before relying
+	// on round robin alternating between the two.
+	waitForBackendCount(t, listener, 2)
+
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		resp, err := listener.Send("ping")
+		if err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		seen[resp] = true
+	}
+	if !seen["one"] || !seen["two"] {
+		t.Errorf("expected round robin to hit both backends, got %v", seen)
+	}
+}
+
+func TestListener_LeastOutstanding(t *testing.T) {
+	listener := AddListenerWithOptions("127.0.0.1:0", ListenerOptions{Strategy: StrategyLeastOutstanding})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error { return listener.Ready() })
+	errg.Go(func() error {
+		conn := dialAndHandshake(t, listener.addr, "busy")
+		go func() {
+			defer conn.Close()
+			if _, _, err := readTestFrame(conn); err != nil {
+				return
+			}
+			<-make(chan struct{}) // never answer; block until the test closes the connection
+		}()
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	// "busy" reads a request and never answers it, so it has one request
+	// permanently outstanding once this fires.
+	go func() { _, _ = listener.Send("stuck") }()
+	waitForBackendPending(t, listener, "busy", 1)
+
+	conn2 := dialAndHandshake(t, listener.addr, "idle")
+	go mockEchoWithID(t, conn2, "idle")
+	waitForBackendCount(t, listener, 2)
+
+	resp, err := listener.Send("ping")
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if resp != "idle" {
+		t.Errorf("expected the idle backend to serve the request, got %q", resp)
+	}
+}
+
+func TestListener_Failover(t *testing.T) {
+	listener := AddListenerWithOptions("127.0.0.1:0", ListenerOptions{Strategy: StrategyRoundRobin})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error { return listener.Ready() })
+	errg.Go(func() error {
+		conn := dialAndHandshake(t, listener.addr, "flaky")
+		conn.Close() // drop immediately so the first Send attempt fails
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	conn2 := dialAndHandshake(t, listener.addr, "healthy")
+	go mockEchoWithID(t, conn2, "healthy")
+	waitForBackendCount(t, listener, 1)
+
+	resp, err := listener.Send("ping")
+	if err != nil {
+		t.Fatalf("expected failover to the healthy backend, got error: %v", err)
+	}
+	if resp != "healthy" {
+		t.Errorf("expected 'healthy', got %q", resp)
+	}
+}
+
+func TestListener_Backends(t *testing.T) {
+	listener := AddListenerWithOptions("127.0.0.1:0", ListenerOptions{Strategy: StrategyRoundRobin})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error { return listener.Ready() })
+	errg.Go(func() error {
+		conn := dialAndHandshake(t, listener.addr, "alpha")
+		go mockEchoWithID(t, conn, "alpha")
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	if _, err := listener.Send("ping"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	backends := listener.Backends()
+	if len(backends) != 1 {
+		t.Fatalf("expected 1 backend, got %d", len(backends))
+	}
+	if backends[0].ID != "alpha" {
+		t.Errorf("expected backend ID 'alpha', got %q", backends[0].ID)
+	}
+	if backends[0].Sent != 1 {
+		t.Errorf("expected Sent=1, got %d", backends[0].Sent)
+	}
+}
+
+// mockEchoWithID behaves like mockClient, but ignores the payload and
+// replies with id instead, so a test can tell which backend served a
+// request. The caller is expected to have already completed the
+// handshake via dialAndHandshake.
+func mockEchoWithID(t *testing.T, conn net.Conn, id string) {
+	defer conn.Close()
+	for {
+		reqID, _, err := readTestFrame(conn)
+		if err != nil {
+			return
+		}
+		if err := writeTestFrame(conn, reqID, id); err != nil {
+			return
+		}
+	}
+}
+
+// mockEcho behaves like mockClient, echoing each request's payload back,
+// but (like mockEchoWithID) expects the caller to have already completed
+// the handshake via dialAndHandshake.
+func mockEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	for {
+		id, payload, err := readTestFrame(conn)
+		if err != nil {
+			return
+		}
+		if err := writeTestFrame(conn, id, payload); err != nil {
+			return
+		}
+	}
+}
+
+func waitForBackendCount(t *testing.T, l listener, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if len(l.Backends()) >= n {
+			return
+		}
+		if err := l.Next(); err != nil {
+			break
+		}
+	}
+	if len(l.Backends()) < n {
+		t.Fatalf("timed out waiting for %d backends, have %d", n, len(l.Backends()))
+	}
+}
+
+func waitForBackendPending(t *testing.T, l listener, id string, pending int64) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		for _, b := range l.Backends() {
+			if b.ID == id && b.Pending >= pending {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for backend %q to have pending >= %d", id, pending)
+}
+
+func TestListener_AddListenerWithOptions_ExclusiveMatchesAddListener(t *testing.T) {
+	listener := AddListenerWithOptions("127.0.0.1:0", ListenerOptions{Strategy: StrategyExclusive})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error { return listener.Ready() })
+	errg.Go(func() error {
+		conn := dialAndHandshake(t, listener.addr, "")
+		go mockEcho(t, conn)
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	resp, err := listener.Send("hello")
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if resp != "hello" {
+		t.Errorf("expected 'hello', got %q", resp)
+	}
+
+	conn2 := dialAndHandshake(t, listener.addr, "")
+	go mockEcho(t, conn2)
+	waitForBackendCount(t, listener, 1)
+	if backends := listener.Backends(); len(backends) != 1 {
+		t.Errorf("expected exclusive strategy to keep exactly one backend, got %d", len(backends))
+	}
+}
@@ -0,0 +1,91 @@
+package dosqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// TestQueryContext_MultiChunkStreaming drives a query whose result arrives
+// as several QueryStreamFrame chunks rather than a single frame, the way a
+// real worker batches a large result set FetchSize rows at a time, and
+// checks that Rows.Next walks across chunk boundaries transparently.
+func TestQueryContext_MultiChunkStreaming(t *testing.T) {
+	listener := AddListener("127.0.0.1:0")
+	defer listener.Close()
+
+	mkFrame := func(f QueryStreamFrame) string {
+		data, err := json.Marshal(f)
+		if err != nil {
+			t.Fatalf("marshal frame: %v", err)
+		}
+		return string(data)
+	}
+
+	frames := []string{
+		mkFrame(QueryStreamFrame{Columns: []string{"id"}, ColumnTypes: []string{"INTEGER"}}),
+		mkFrame(QueryStreamFrame{Chunk: [][]interface{}{
+			{JSONValue{Type: "int", V: "1"}},
+			{JSONValue{Type: "int", V: "2"}},
+		}}),
+		mkFrame(QueryStreamFrame{Chunk: [][]interface{}{
+			{JSONValue{Type: "int", V: "3"}},
+		}}),
+		mkFrame(QueryStreamFrame{Done: true}),
+	}
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error {
+		return listener.Ready()
+	})
+	errg.Go(func() error {
+		conn, err := net.Dial("tcp", listener.addr)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		go streamingClient(t, conn, frames)
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	c := &Conn{listener: listener}
+	rows, err := c.QueryContext(context.Background(), "select id from t", nil)
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	defer rows.Close()
+
+	if cols := rows.Columns(); len(cols) != 1 || cols[0] != "id" {
+		t.Fatalf("expected columns [id], got %v", cols)
+	}
+
+	var got []int64
+	dest := make([]driver.Value, 1)
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, dest[0].(int64))
+	}
+
+	want := []int64{1, 2, 3}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rows across chunks, got %d: %v", len(want), len(got), got)
+	}
+	for i, v := range want {
+		if got[i] != v {
+			t.Errorf("row %d: expected %d, got %d", i, v, got[i])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package dosqlite
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// tlsConfigFromQuery builds the *tls.Config a dosqlites:// DSN describes via
+// query parameters:
+//
+//	cert, key - PEM files for the keypair dosqlite presents to connecting
+//	            workers; must both be set, or both be absent
+//	ca        - a PEM file of CA certificates used to verify a connecting
+//	            worker's client certificate; its presence turns on mTLS by
+//	            setting ClientAuth to RequireAndVerifyClientCert
+func tlsConfigFromQuery(q url.Values) (*tls.Config, error) {
+	certFile, keyFile := q.Get("cert"), q.Get("key")
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("dosqlite: cert and key must both be set in the DSN")
+	}
+
+	cfg := &tls.Config{}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("dosqlite: failed to load tls keypair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile := q.Get("ca"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("dosqlite: failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("dosqlite: failed to parse ca file %q", caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,183 @@
+package dosqlite
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// generateTestCert writes a self-signed ECDSA cert/key pair to PEM files
+// under t.TempDir() and returns their paths
+func generateTestCert(t *testing.T) (certPath, keyPath string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dosqlite-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyBytes)
+
+	return certPath, keyPath
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestListener_AddListenerTLS(t *testing.T) {
+	certPath, keyPath := generateTestCert(t)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to load keypair: %v", err)
+	}
+
+	listener := AddListenerTLS("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer listener.Close()
+
+	errg, _ := errgroup.WithContext(context.Background())
+	errg.Go(func() error {
+		return listener.Ready()
+	})
+	errg.Go(func() error {
+		conn, err := tls.Dial("tcp", listener.addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("Failed to dial TLS: %v", err)
+		}
+		go mockClient(t, conn)
+		return nil
+	})
+	if err := errg.Wait(); err != nil {
+		t.Fatalf("Failed to wait for listener to be ready: %v", err)
+	}
+
+	resp, err := listener.Send("hello")
+	if err != nil {
+		t.Fatalf("Failed to send over TLS: %v", err)
+	}
+	if resp != "hello" {
+		t.Errorf("Expected 'hello', got %q", resp)
+	}
+}
+
+func TestListener_AddListenerTLS_RejectsPlaintext(t *testing.T) {
+	certPath, keyPath := generateTestCert(t)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to load keypair: %v", err)
+	}
+
+	listener := AddListenerTLS("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Write raw bytes rather than completing a TLS handshake; the server
+	// should never treat this as a valid dosqlite handshake byte.
+	if _, err := conn.Write([]byte("not tls")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("expected the TLS handshake to fail for a plaintext client")
+	}
+}
+
+func TestTLSConfigFromQuery(t *testing.T) {
+	certPath, keyPath := generateTestCert(t)
+
+	t.Run("no tls params", func(t *testing.T) {
+		cfg, err := tlsConfigFromQuery(url.Values{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Certificates) != 0 || cfg.ClientAuth != tls.NoClientCert {
+			t.Errorf("expected a bare config, got %+v", cfg)
+		}
+	})
+
+	t.Run("cert and key", func(t *testing.T) {
+		q := url.Values{"cert": {certPath}, "key": {keyPath}}
+		cfg, err := tlsConfigFromQuery(q)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Certificates) != 1 {
+			t.Errorf("expected one certificate, got %d", len(cfg.Certificates))
+		}
+	})
+
+	t.Run("cert without key", func(t *testing.T) {
+		q := url.Values{"cert": {certPath}}
+		if _, err := tlsConfigFromQuery(q); err == nil {
+			t.Error("expected an error when key is missing")
+		}
+	})
+
+	t.Run("ca enables mtls", func(t *testing.T) {
+		q := url.Values{"ca": {certPath}}
+		cfg, err := tlsConfigFromQuery(q)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("expected RequireAndVerifyClientCert, got %v", cfg.ClientAuth)
+		}
+		if cfg.ClientCAs == nil {
+			t.Error("expected ClientCAs to be set")
+		}
+	})
+}
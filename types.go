@@ -4,99 +4,265 @@ import (
 	"database/sql/driver"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"time"
 )
 
 // ExecRequest represents a request to execute a non-query SQL statement
 type ExecRequest struct {
-	Cmd    string        `json:"cmd"`    // "exec"
-	SQL    string        `json:"sql"`
-	Params []interface{} `json:"params"`
+	Cmd       string        `json:"cmd"`    // "exec"
+	SQL       string        `json:"sql"`
+	Params    []interface{} `json:"params"`
+	TxID      string        `json:"tx_id,omitempty"`
+	TimeoutMs int64         `json:"timeout_ms,omitempty"`
 }
 
 // ExecResponse represents the response from executing a non-query SQL statement
 type ExecResponse struct {
+	OK              bool   `json:"ok"`
+	LastInsertRowID int64  `json:"last_insert_rowid"`
+	Changes         int64  `json:"changes"`
+	Error           string `json:"error,omitempty"`
+}
+
+// QueryRequest represents a request to execute a query SQL statement. The
+// response is not a single response object but a sequence of
+// QueryStreamFrame frames, chunked to FetchSize rows at a time.
+type QueryRequest struct {
+	Cmd       string        `json:"cmd"`    // "query"
+	SQL       string        `json:"sql"`
+	Params    []interface{} `json:"params"`
+	TxID      string        `json:"tx_id,omitempty"`
+	TimeoutMs int64         `json:"timeout_ms,omitempty"`
+	FetchSize int           `json:"fetch_size,omitempty"`
+}
+
+// defaultFetchSize is the number of rows the worker batches into each
+// QueryStreamFrame when the caller doesn't request a different size
+const defaultFetchSize = 500
+
+// QueryStreamFrame is one frame of a streamed query result. The first frame
+// carries Columns/ColumnTypes with no Chunk; every following frame carries a
+// batch of up to FetchSize rows in Chunk; the stream ends with a frame
+// carrying Done or Error. This lets a large result set be consumed row by
+// row as it arrives instead of buffering the entire result on either side of
+// the wire.
+type QueryStreamFrame struct {
+	Columns     []string        `json:"columns,omitempty"`
+	ColumnTypes []string        `json:"column_types,omitempty"`
+	Chunk       [][]interface{} `json:"chunk,omitempty"`
+	Done        bool            `json:"done,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// CancelRequest asks the worker to interrupt RequestID specifically, via
+// SQLite's interrupt(), rather than whatever statement happens to be
+// running on the connection. Since request-ID-tagged framing lets several
+// requests be pipelined over one connection at once, the worker must check
+// that RequestID is the one actually executing before interrupting, so an
+// expired context never aborts an unrelated sibling request.
+type CancelRequest struct {
+	Cmd       string `json:"cmd"` // "cancel"
+	RequestID uint64 `json:"request_id"`
+}
+
+// CancelResponse represents the response from a cancel request
+type CancelResponse struct {
 	OK    bool   `json:"ok"`
 	Error string `json:"error,omitempty"`
 }
 
-// QueryRequest represents a request to execute a query SQL statement
-type QueryRequest struct {
-	Cmd    string        `json:"cmd"`    // "query"
-	SQL    string        `json:"sql"`
-	Params []interface{} `json:"params"`
+// PrepareRequest represents a request to compile and cache a SQL statement
+// on the worker
+type PrepareRequest struct {
+	Cmd string `json:"cmd"` // "prepare"
+	SQL string `json:"sql"`
+}
+
+// PrepareResponse represents the response from preparing a statement
+type PrepareResponse struct {
+	OK     bool   `json:"ok"`
+	StmtID string `json:"stmt_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ExecStmtRequest represents a request to execute a previously prepared
+// statement
+type ExecStmtRequest struct {
+	Cmd       string        `json:"cmd"` // "exec_stmt"
+	StmtID    string        `json:"stmt_id"`
+	Params    []interface{} `json:"params"`
+	TxID      string        `json:"tx_id,omitempty"`
+	TimeoutMs int64         `json:"timeout_ms,omitempty"`
+}
+
+// QueryStmtRequest represents a request to query a previously prepared
+// statement. Like QueryRequest, the response is a stream of
+// QueryStreamFrame frames rather than a single response object.
+type QueryStmtRequest struct {
+	Cmd       string        `json:"cmd"` // "query_stmt"
+	StmtID    string        `json:"stmt_id"`
+	Params    []interface{} `json:"params"`
+	TxID      string        `json:"tx_id,omitempty"`
+	FetchSize int           `json:"fetch_size,omitempty"`
+	TimeoutMs int64         `json:"timeout_ms,omitempty"`
+}
+
+// CloseStmtRequest represents a request to release a prepared statement on
+// the worker
+type CloseStmtRequest struct {
+	Cmd    string `json:"cmd"` // "close_stmt"
+	StmtID string `json:"stmt_id"`
+}
+
+// CloseStmtResponse represents the response from closing a prepared
+// statement
+type CloseStmtResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// BeginRequest represents a request to open a transaction on the worker
+type BeginRequest struct {
+	Cmd       string `json:"cmd"` // "begin"
+	Isolation string `json:"isolation,omitempty"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// BeginResponse represents the response from opening a transaction
+type BeginResponse struct {
+	OK    bool   `json:"ok"`
+	TxID  string `json:"tx_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// CommitRequest represents a request to commit a transaction
+type CommitRequest struct {
+	Cmd  string `json:"cmd"` // "commit"
+	TxID string `json:"tx_id"`
 }
 
-// QueryResponse represents the response from executing a query SQL statement
-type QueryResponse struct {
-	OK    bool                     `json:"ok"`
-	Rows  []map[string]interface{} `json:"rows,omitempty"`
-	Error string                   `json:"error,omitempty"`
+// CommitResponse represents the response from committing a transaction
+type CommitResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// RollbackRequest represents a request to roll back a transaction
+type RollbackRequest struct {
+	Cmd  string `json:"cmd"` // "rollback"
+	TxID string `json:"tx_id"`
+}
+
+// RollbackResponse represents the response from rolling back a transaction
+type RollbackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
 }
 
-// BlobValue represents binary data for JSON encoding
-type BlobValue struct {
-	Type string `json:"type"` // "blob"
-	Data string `json:"data"` // base64 encoded
+// JSONValue is the tagged envelope every driver.Value is wrapped in before
+// crossing the JSON boundary. The Type matches one of SQLite's five storage
+// classes (int, real, text, blob, null) plus a "time" extension, so the
+// worker and the Go driver always agree on how to interpret V without
+// guessing from its shape. Int values are carried as decimal strings to
+// avoid JavaScript's 53-bit safe integer limit truncating 64-bit rowids.
+type JSONValue struct {
+	Type string      `json:"type"`
+	V    interface{} `json:"v,omitempty"`
 }
 
-// convertDriverValueToJSON converts database/sql driver values to JSON-safe values
+// convertDriverValueToJSON converts a database/sql driver value into its
+// tagged JSONValue envelope
 func convertDriverValueToJSON(v driver.Value) interface{} {
 	switch val := v.(type) {
 	case nil:
-		return nil
+		return JSONValue{Type: "null"}
 	case int64:
-		return val
+		return JSONValue{Type: "int", V: strconv.FormatInt(val, 10)}
 	case float64:
-		return val
+		return JSONValue{Type: "real", V: val}
 	case string:
-		return val
+		return JSONValue{Type: "text", V: val}
 	case []byte:
 		if val == nil {
-			return nil
-		}
-		// Encode binary data as base64 with type marker
-		return BlobValue{
-			Type: "blob",
-			Data: base64.StdEncoding.EncodeToString(val),
+			return JSONValue{Type: "null"}
 		}
+		return JSONValue{Type: "blob", V: base64.StdEncoding.EncodeToString(val)}
 	case bool:
-		return val // JSON supports booleans natively
+		// SQLite has no boolean storage class; store it the way SQLite
+		// itself does, as the integer 0 or 1.
+		if val {
+			return JSONValue{Type: "int", V: "1"}
+		}
+		return JSONValue{Type: "int", V: "0"}
 	case time.Time:
-		return val.Unix() // Convert to Unix timestamp
+		return JSONValue{Type: "time", V: val.Format(time.RFC3339Nano)}
 	default:
-		return fmt.Sprintf("%v", val) // Fallback to string
+		return JSONValue{Type: "text", V: fmt.Sprintf("%v", val)}
 	}
 }
 
-// convertJSONValueToDriver converts JSON values back to database/sql driver values
+// convertNamedValuesToJSON converts a slice of driver.NamedValue (as passed
+// to ExecContext/QueryContext) into its JSON-safe params representation
+func convertNamedValuesToJSON(args []driver.NamedValue) []interface{} {
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = convertDriverValueToJSON(arg.Value)
+	}
+	return params
+}
+
+// convertDriverValuesToJSON converts a slice of driver.Value (as passed to
+// Stmt.Exec/Stmt.Query) into its JSON-safe params representation
+func convertDriverValuesToJSON(args []driver.Value) []interface{} {
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = convertDriverValueToJSON(arg)
+	}
+	return params
+}
+
+// convertJSONValueToDriver converts a tagged JSONValue envelope (decoded by
+// encoding/json into a map[string]interface{}) back into a database/sql
+// driver value
 func convertJSONValueToDriver(v interface{}) driver.Value {
-	switch val := v.(type) {
-	case nil:
+	val, ok := v.(map[string]interface{})
+	if !ok {
 		return nil
-	case float64:
-		// JSON numbers are always float64, convert integers back
-		if val == float64(int64(val)) {
-			return int64(val)
+	}
+
+	typeVal, _ := val["type"].(string)
+	switch typeVal {
+	case "null":
+		return nil
+	case "int":
+		s, _ := val["v"].(string)
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil
 		}
-		return val
-	case string:
-		return val
-	case bool:
-		return val
-	case map[string]interface{}:
-		// Check if it's a blob value
-		if typeVal, ok := val["type"].(string); ok && typeVal == "blob" {
-			if dataVal, ok := val["data"].(string); ok {
-				decoded, err := base64.StdEncoding.DecodeString(dataVal)
-				if err != nil {
-					return nil // Invalid base64, return null
-				}
-				return decoded
-			}
+		return n
+	case "real":
+		f, _ := val["v"].(float64)
+		return f
+	case "text":
+		s, _ := val["v"].(string)
+		return s
+	case "blob":
+		s, _ := val["v"].(string)
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil
 		}
-		return fmt.Sprintf("%v", val) // Fallback to string for unknown objects
+		return decoded
+	case "time":
+		s, _ := val["v"].(string)
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil
+		}
+		return t
 	default:
-		return fmt.Sprintf("%v", val)
+		return nil
 	}
 }
\ No newline at end of file